@@ -1,66 +1,93 @@
 package archive
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"log/slog"
+	"os"
 	"path/filepath"
 
 	"github.com/holgerhuo/gobackup/config"
 	"github.com/holgerhuo/gobackup/helper"
 )
 
-// Run archive
-func Run(model config.ModelConfig) (err error) {
+// Run archive, packs includes into archive.tar under the dump path of model
+func Run(runCtx context.Context, model config.ModelConfig) (err error) {
 	if model.Archive == nil {
 		return nil
 	}
 
-	slog.Info("Starting archive creation", 
+	slog.Info("Starting archive creation",
 		"component", "archive",
 		"model", model.Name)
 
 	helper.MkdirP(model.DumpPath)
+	tarPath := filepath.Join(model.DumpPath, "archive.tar")
+
+	if helper.IsDryRun(runCtx) {
+		includes, excludes, err := paths(model)
+		if err != nil {
+			return err
+		}
+		slog.Info("Dry run, archive skipped",
+			"component", "archive",
+			"model", model.Name,
+			"includes", includes,
+			"excludes", excludes,
+			"archivePath", tarPath)
+		return nil
+	}
 
-	includes := model.Archive.GetStringSlice("includes")
-	includes = cleanPaths(includes)
-
-	excludes := model.Archive.GetStringSlice("excludes")
-	excludes = cleanPaths(excludes)
-
-	if len(includes) == 0 {
-		return fmt.Errorf("archive.includes have no config")
+	file, err := os.OpenFile(tarPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
 	}
-	slog.Info("Archive configuration", 
-		"component", "archive",
-		"model", model.Name,
-		"includeRules", len(includes),
-		"excludeRules", len(excludes))
+	defer file.Close()
 
-	opts := options(model.DumpPath, excludes, includes)
-	helper.Exec("tar", opts...)
+	if err = Stream(runCtx, model, file); err != nil {
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
 
-	slog.Info("Archive creation completed", 
+	slog.Info("Archive creation completed",
 		"component", "archive",
 		"model", model.Name,
-		"archivePath", filepath.Join(model.DumpPath, "archive.tar"))
+		"archivePath", tarPath)
 
 	return nil
 }
 
-func options(dumpPath string, excludes, includes []string) (opts []string) {
-	tarPath := filepath.Join(dumpPath, "archive.tar")
-	if helper.IsGnuTar {
-		opts = append(opts, "--ignore-failed-read")
+// Stream writes the includes of model into w as a tar, like
+// `tar -cPf - --ignore-failed-read` with every exclude. It stops when runCtx
+// is done or the archive timeout expired.
+func Stream(runCtx context.Context, model config.ModelConfig, w io.Writer) error {
+	includes, excludes, err := paths(model)
+	if err != nil {
+		return err
 	}
-	opts = append(opts, "-cPf", tarPath)
 
-	for _, exclude := range excludes {
-		opts = append(opts, "--exclude="+filepath.Clean(exclude))
-	}
+	slog.Info("Archive configuration",
+		"component", "archive",
+		"model", model.Name,
+		"includeRules", len(includes),
+		"excludeRules", len(excludes))
 
-	opts = append(opts, includes...)
+	runCtx, cancel := helper.WithTimeout(runCtx, "archive", model.ArchiveTimeout)
+	defer cancel()
+	return writeTar(helper.NewContextWriter(runCtx, w), includes, excludes)
+}
+
+func paths(model config.ModelConfig) (includes, excludes []string, err error) {
+	includes = cleanPaths(model.Archive.GetStringSlice("includes"))
+	excludes = cleanPaths(model.Archive.GetStringSlice("excludes"))
 
-	return opts
+	if len(includes) == 0 {
+		return nil, nil, fmt.Errorf("archive.includes have no config")
+	}
+	return
 }
 
 func cleanPaths(paths []string) (results []string) {
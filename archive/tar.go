@@ -0,0 +1,87 @@
+package archive
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/holgerhuo/gobackup/helper"
+)
+
+// writeTar packs includes into w with their full paths, unreadable files are
+// skipped with a warning, same as GNU tar --ignore-failed-read.
+func writeTar(w io.Writer, includes, excludes []string) error {
+	tw := tar.NewWriter(w)
+
+	for _, include := range includes {
+		err := filepath.WalkDir(include, func(filePath string, d fs.DirEntry, err error) error {
+			if err != nil {
+				warnSkipped(filePath, err)
+				return nil
+			}
+			if isExcluded(filePath, excludes) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			err = helper.AddTarEntry(tw, filePath, filePath, d)
+			var unreadable *helper.UnreadableError
+			if errors.As(err, &unreadable) {
+				warnSkipped(filePath, unreadable.Err)
+				return nil
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tw.Close()
+}
+
+// isExcluded matches like GNU tar --exclude: a glob matching the whole path
+// or any trailing part of it, for the path or any of its parents, so
+// "*.log" and "node_modules" exclude at every depth.
+func isExcluded(filePath string, excludes []string) bool {
+	for _, exclude := range excludes {
+		exclude = filepath.Clean(exclude)
+		for dir := filepath.Clean(filePath); ; dir = filepath.Dir(dir) {
+			if matchSuffix(exclude, dir) {
+				return true
+			}
+			if filepath.Dir(dir) == dir {
+				break
+			}
+		}
+	}
+	return false
+}
+
+// matchSuffix tells whether pattern matches filePath or any part of it
+// following a separator
+func matchSuffix(pattern, filePath string) bool {
+	for {
+		if matched, _ := filepath.Match(pattern, filePath); matched {
+			return true
+		}
+		i := strings.IndexRune(filePath, os.PathSeparator)
+		if i < 0 {
+			return false
+		}
+		filePath = filePath[i+1:]
+	}
+}
+
+func warnSkipped(filePath string, err error) {
+	slog.Warn("Archive skipped unreadable path",
+		"component", "archive",
+		"path", filePath,
+		"error", err)
+}
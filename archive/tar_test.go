@@ -0,0 +1,75 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestIsExcluded(t *testing.T) {
+	cases := []struct {
+		path     string
+		excludes []string
+		want     bool
+	}{
+		{path: "/src/a.txt", excludes: nil, want: false},
+		{path: "/src/logs", excludes: []string{"/src/logs"}, want: true},
+		{path: "/src/logs/a.txt", excludes: []string{"/src/logs"}, want: true},
+		{path: "/src/logs/a.txt", excludes: []string{"/src/logs/"}, want: true},
+		{path: "/src/logsold/a.txt", excludes: []string{"/src/logs"}, want: false},
+		{path: "/src/a.log", excludes: []string{"*.log"}, want: true},
+		{path: "/src/sub/b.log", excludes: []string{"*.log"}, want: true},
+		{path: "/src/sub/b.log.gz", excludes: []string{"*.log"}, want: false},
+		{path: "/src/app/node_modules", excludes: []string{"node_modules"}, want: true},
+		{path: "/src/node_modules_bak", excludes: []string{"node_modules"}, want: false},
+		{path: "/src/sub/c.tmp", excludes: []string{"*.log", "*.tmp"}, want: true},
+		{path: "/src/sub/cache/x", excludes: []string{"sub/cache"}, want: true},
+		{path: "/src/other/cache", excludes: []string{"sub/cache"}, want: false},
+		{path: "/src/sub/a.log", excludes: []string{"/src/*/a.log"}, want: true},
+	}
+	for _, c := range cases {
+		path := filepath.FromSlash(c.path)
+		excludes := make([]string, len(c.excludes))
+		for i, exclude := range c.excludes {
+			excludes[i] = filepath.FromSlash(exclude)
+		}
+		if got := isExcluded(path, excludes); got != c.want {
+			t.Errorf("isExcluded(%q, %q) = %v, want %v", c.path, c.excludes, got, c.want)
+		}
+	}
+}
+
+func TestWriteTarSkipsUnreadable(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Getuid() == 0 {
+		t.Skip("file permissions aren't enforced")
+	}
+
+	srcDir := t.TempDir()
+	os.WriteFile(filepath.Join(srcDir, "ok.conf"), []byte("ok"), 0600)
+	os.WriteFile(filepath.Join(srcDir, "secret.conf"), []byte("no"), 0000)
+
+	buf := &bytes.Buffer{}
+	if err := writeTar(buf, []string{srcDir}, nil); err != nil {
+		t.Fatalf("writeTar() error = %v", err)
+	}
+
+	names := map[string]bool{}
+	tr := tar.NewReader(buf)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names[filepath.Base(header.Name)] = true
+	}
+	if !names["ok.conf"] || names["secret.conf"] {
+		t.Errorf("archived %v, want ok.conf without secret.conf", names)
+	}
+}
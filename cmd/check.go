@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/holgerhuo/gobackup/config"
+	"github.com/spf13/cobra"
+)
+
+var checkCmd = &cobra.Command{
+	Use:     "check",
+	Aliases: []string{"validate"},
+	Short:   "check config file",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// secrets may only exist where gobackup runs, they aren't resolved
+		cfg, err := config.LoadUnresolved(configFile)
+		if err != nil {
+			return err
+		}
+
+		problems := cfg.Check()
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("found %d problem(s) in config", len(problems))
+		}
+
+		fmt.Println("config is valid")
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+}
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/holgerhuo/gobackup/config"
+	"github.com/holgerhuo/gobackup/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	listOutput string
+)
+
+// backupEntry a backup printed by list command
+type backupEntry struct {
+	Model      string    `json:"model"`
+	Storage    string    `json:"storage"`
+	Type       string    `json:"type"`
+	Key        string    `json:"key"`
+	Size       int64     `json:"size"`
+	Timestamp  time.Time `json:"timestamp"`
+	AgeSeconds int64     `json:"age_seconds"`
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list backups kept in storages",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if listOutput != "table" && listOutput != "json" {
+			return fmt.Errorf("unknown output %s, must be table or json", listOutput)
+		}
+		// stdout only holds the listing, logs go to stderr
+		setLogger(os.Stderr)
+
+		cfg, err := config.Load(configFile)
+		if err != nil {
+			return err
+		}
+
+		models := cfg.Models
+		if len(modelName) > 0 {
+			modelConfig := cfg.GetModelByName(modelName)
+			if modelConfig == nil {
+				return fmt.Errorf("model %s not found", modelName)
+			}
+			models = []config.ModelConfig{*modelConfig}
+		}
+		sort.Slice(models, func(i, j int) bool {
+			return models[i].Name < models[j].Name
+		})
+
+		now := time.Now()
+		entries := []backupEntry{}
+		failed := 0
+		for _, modelConfig := range models {
+			for _, listing := range storage.List(cmd.Context(), modelConfig) {
+				if listing.Err != nil {
+					failed++
+					slog.Error("List storage failed",
+						"component", "storage",
+						"model", listing.Model,
+						"storage", listing.Storage,
+						"type", listing.Type,
+						"error", listing.Err)
+					continue
+				}
+				for _, item := range listing.Items {
+					entries = append(entries, backupEntry{
+						Model:      listing.Model,
+						Storage:    listing.Storage,
+						Type:       listing.Type,
+						Key:        item.Key,
+						Size:       item.Size,
+						Timestamp:  item.LastModified,
+						AgeSeconds: int64(now.Sub(item.LastModified).Seconds()),
+					})
+				}
+			}
+		}
+
+		if listOutput == "json" {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(entries); err != nil {
+				return err
+			}
+		} else {
+			printBackupEntries(entries, now)
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("%d storage(s) could not be listed", failed)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+
+	listCmd.Flags().StringVarP(&modelName, "model", "m", "", "the model to list, default to all models")
+	listCmd.Flags().StringVarP(&listOutput, "output", "o", "table", "print backups as table or json")
+}
+
+func printBackupEntries(entries []backupEntry, now time.Time) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MODEL\tSTORAGE\tKEY\tSIZE\tTIMESTAMP\tAGE")
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			entry.Model,
+			entry.Storage,
+			entry.Key,
+			formatSize(entry.Size),
+			entry.Timestamp.Local().Format(time.RFC3339),
+			formatAge(now.Sub(entry.Timestamp)))
+	}
+	w.Flush()
+}
+
+// formatSize 1536 -> 1.5 KiB
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// formatAge 26h -> 1d2h
+func formatAge(age time.Duration) string {
+	if age < time.Minute {
+		return "just now"
+	}
+	days := int(age.Hours()) / 24
+	hours := int(age.Hours()) % 24
+	minutes := int(age.Minutes()) % 60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd%dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh%dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
@@ -1,26 +1,40 @@
 package cmd
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
 	"github.com/holgerhuo/gobackup/config"
+	"github.com/holgerhuo/gobackup/helper"
 	"github.com/holgerhuo/gobackup/model"
 	"github.com/spf13/cobra"
 )
 
 var (
 	modelName string
+	dryRun    bool
 )
 
 var performCmd = &cobra.Command{
 	Use:   "perform",
 	Short: "perform backup",
-	Run: func(cmd *cobra.Command, args []string) {
-		config.Init(configFile)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load(configFile)
+		if err != nil {
+			return err
+		}
+		ctx := cmd.Context()
+		if dryRun {
+			ctx = helper.WithDryRun(ctx)
+		}
 
 		if len(modelName) == 0 {
-			performAll()
-		} else {
-			performOne(modelName)
+			return performAll(ctx, cfg)
 		}
+		return performOne(ctx, cfg, modelName)
 	},
 }
 
@@ -28,23 +42,93 @@ func init() {
 	rootCmd.AddCommand(performCmd)
 
 	performCmd.Flags().StringVarP(&modelName, "model", "m", "", "the model to perform")
+	performCmd.Flags().BoolVar(&dryRun, "dry-run", false, "print commands and destinations without running them")
 }
 
-func performAll() {
-	for _, modelConfig := range config.Models {
+// validateModels checks every model before any of them starts
+func validateModels(models []config.ModelConfig) error {
+	var errs []error
+	for _, modelConfig := range models {
 		m := model.Model{
 			Config: modelConfig,
 		}
-		m.Perform()
+		if err := m.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config:\n%w", errors.Join(errs...))
 	}
+	return nil
 }
 
-func performOne(modelName string) {
-	modelConfig := config.GetModelByName(modelName)
-	if modelConfig != nil {
+func performAll(ctx context.Context, cfg *config.Config) error {
+	if err := validateModels(cfg.Models); err != nil {
+		return err
+	}
+
+	startedAt := time.Now()
+	// a failed model doesn't stop the others, every model runs once
+	errs := helper.Parallel(len(cfg.Models), cfg.Concurrency, false, func(i int) error {
 		m := model.Model{
-			Config: *modelConfig,
+			Config: cfg.Models[i],
+		}
+		modelStartedAt := time.Now()
+		err := m.Perform(ctx)
+		logResult(m.Config.Name, time.Since(modelStartedAt), err)
+		return err
+	})
+
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
 		}
-		m.Perform()
 	}
+
+	slog.Info("Backup summary",
+		"component", "perform",
+		"total", len(cfg.Models),
+		"concurrency", cfg.Concurrency,
+		"succeeded", len(cfg.Models)-failed,
+		"failed", failed,
+		"duration", time.Since(startedAt).Round(time.Millisecond).String())
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d models failed", failed, len(cfg.Models))
+	}
+	return nil
+}
+
+func performOne(ctx context.Context, cfg *config.Config, modelName string) error {
+	modelConfig := cfg.GetModelByName(modelName)
+	if modelConfig == nil {
+		return fmt.Errorf("model %s not found", modelName)
+	}
+	if err := validateModels([]config.ModelConfig{*modelConfig}); err != nil {
+		return err
+	}
+
+	m := model.Model{
+		Config: *modelConfig,
+	}
+	startedAt := time.Now()
+	err := m.Perform(ctx)
+	logResult(modelName, time.Since(startedAt), err)
+	return err
+}
+
+func logResult(name string, duration time.Duration, err error) {
+	if err != nil {
+		slog.Error("Backup model failed",
+			"component", "perform",
+			"model", name,
+			"duration", duration.Round(time.Millisecond).String(),
+			"error", err)
+		return
+	}
+	slog.Info("Backup model succeeded",
+		"component", "perform",
+		"model", name,
+		"duration", duration.Round(time.Millisecond).String())
 }
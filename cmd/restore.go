@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/holgerhuo/gobackup/config"
+	"github.com/holgerhuo/gobackup/model"
+	"github.com/spf13/cobra"
+)
+
+var (
+	restoreOptions model.RestoreOptions
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "restore a backup from storage",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load(configFile)
+		if err != nil {
+			return err
+		}
+
+		modelConfig := cfg.GetModelByName(modelName)
+		if modelConfig == nil {
+			return fmt.Errorf("model %s not found", modelName)
+		}
+		if err := validateModels([]config.ModelConfig{*modelConfig}); err != nil {
+			return err
+		}
+
+		m := model.Model{
+			Config: *modelConfig,
+		}
+		return m.Restore(cmd.Context(), restoreOptions)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(restoreCmd)
+
+	restoreCmd.Flags().StringVarP(&modelName, "model", "m", "", "the model to restore")
+	restoreCmd.MarkFlagRequired("model")
+	restoreCmd.Flags().StringVar(&restoreOptions.Storage, "storage", "", "storage name to fetch from, default to the first storage")
+	restoreCmd.Flags().StringVar(&restoreOptions.FileKey, "key", "", "file key of the backup, default to the latest")
+	restoreCmd.Flags().StringVar(&restoreOptions.Dir, "dir", "", "directory to keep the downloaded and extracted backup")
+	restoreCmd.Flags().BoolVar(&restoreOptions.SkipDatabases, "skip-databases", false, "only download and extract the backup")
+	restoreCmd.Flags().StringVar(&restoreOptions.Identity, "identity", "", "age or gpg private key file, overrides identity_file of encrypt_with")
+	restoreCmd.Flags().StringVar(&restoreOptions.Database.Host, "target-host", "", "restore mysql and postgresql into this host")
+	restoreCmd.Flags().StringVar(&restoreOptions.Database.TargetDir, "target-dir", "", "directory to place redis RDB files")
+}
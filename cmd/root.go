@@ -1,8 +1,12 @@
 package cmd
 
 import (
+	"context"
+	"io"
 	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/spf13/cobra"
 )
@@ -18,10 +22,16 @@ var rootCmd = &cobra.Command{
 	Use:     "gobackup",
 	Short:   "Easy backup solution on Linux",
 	Version: version,
+	// failed backups are not usage errors
+	SilenceUsage: true,
 }
 
+// Execute the command line, SIGINT and SIGTERM cancel the context of the
+// command, killing the commands it runs
 func Execute() {
-	err := rootCmd.Execute()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
 	if err != nil {
 		os.Exit(1)
 	}
@@ -31,11 +41,16 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&configFile, "config", "c", "", "path to config file")
 	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "enable verbose log")
 	rootCmd.PersistentFlags().BoolVar(&jsonLog, "json", false, "output logs in json format")
-	
+
 	cobra.OnInitialize(initLogger)
 }
 
 func initLogger() {
+	setLogger(os.Stdout)
+}
+
+// setLogger writes the logs into w with the level and format of the flags
+func setLogger(w io.Writer) {
 	logLevel := new(slog.LevelVar)
 
 	if debug {
@@ -46,13 +61,13 @@ func initLogger() {
 
 	var handler slog.Handler
 	if jsonLog {
-		handler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-			Level: logLevel,
+		handler = slog.NewJSONHandler(w, &slog.HandlerOptions{
+			Level:     logLevel,
 			AddSource: debug,
 		})
 	} else {
-		handler = slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-			Level: logLevel,
+		handler = slog.NewTextHandler(w, &slog.HandlerOptions{
+			Level:     logLevel,
 			AddSource: debug,
 		})
 	}
@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"github.com/holgerhuo/gobackup/config"
+	"github.com/holgerhuo/gobackup/scheduler"
+	"github.com/spf13/cobra"
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run",
+	Short: "run as daemon, perform models on their schedule",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load(configFile)
+		if err != nil {
+			return err
+		}
+
+		if err := validateModels(cfg.Models); err != nil {
+			return err
+		}
+
+		return scheduler.Run(cmd.Context(), cfg.Models, cfg.Concurrency)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+}
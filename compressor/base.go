@@ -1,66 +1,126 @@
 package compressor
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"path"
+	"runtime"
+	"strings"
 	"time"
 
 	"github.com/holgerhuo/gobackup/config"
+	"github.com/holgerhuo/gobackup/helper"
+	"github.com/holgerhuo/gobackup/plugin"
 	"github.com/spf13/viper"
 )
 
 // Base compressor
 type Base struct {
-	name  string
-	model config.ModelConfig
-	viper *viper.Viper
+	model   config.ModelConfig
+	viper   *viper.Viper
+	level   int
+	threads int
+	// levelSet tells a configured level 0 from no level
+	levelSet bool
 }
 
 // Context compressor
 type Context interface {
-	perform() (archivePath string, err error)
+	// ext of the compressed file, example: .tar.gz
+	ext() string
+	// newWriter compresses everything written into w
+	newWriter(runCtx context.Context, w io.Writer) (io.WriteCloser, error)
+	// newReader decompresses r
+	newReader(runCtx context.Context, r io.Reader) (io.ReadCloser, error)
 }
 
-func (ctx *Base) archiveFilePath(ext string) string {
-	return path.Join(ctx.model.TempPath, time.Now().Format("2006.01.02.15.04.05")+ext)
+// archiveFilePath starts with the model name, so models sharing a storage
+// can tell their backups apart, example: app.2006.01.02.15.04.05.tar.gz
+func archiveFilePath(model config.ModelConfig, ext string) string {
+	return path.Join(model.TempPath, model.Name+"."+time.Now().Format("2006.01.02.15.04.05")+ext)
 }
 
 func newBase(model config.ModelConfig) (base Base) {
 	base = Base{
-		name:  model.Name,
 		model: model,
 		viper: model.CompressWith.Viper,
 	}
+
+	// level 0 means the default level of the compressor, xz tells a
+	// configured 0 apart by levelSet
+	base.threads = runtime.NumCPU()
+	if base.viper != nil {
+		base.level = base.viper.GetInt("level")
+		base.levelSet = base.viper.IsSet("level")
+		if threads := base.viper.GetInt("threads"); threads > 0 {
+			base.threads = threads
+		}
+	}
 	return
 }
 
-// Run compressor
-func Run(model config.ModelConfig) (archivePath string, err error) {
+// newContext uses zstd when model has no compress_with config,
+// unknown types are rejected.
+func newContext(model config.ModelConfig) (Context, error) {
+	typ := model.CompressWith.Type
+	if len(typ) == 0 {
+		typ = "zstd"
+	}
+
 	base := newBase(model)
+	registryMu.RLock()
+	fn, ok := registry[typ]
+	registryMu.RUnlock()
+	if ok {
+		return fn(base)
+	}
+	if p, ok := plugin.Lookup(plugin.KindCompressor, typ); ok {
+		return newExternal(base, pluginFactory(p))
+	}
+	return nil, fmt.Errorf("model: %s compress_with config `type: %s`, but is not implement", model.Name, typ)
+}
+
+// Validate check the compressor type of model is supported
+func Validate(model config.ModelConfig) error {
+	_, err := newContext(model)
+	return err
+}
 
-	var ctx Context
-	switch model.CompressWith.Type {
-	case "tgz":
-		ctx = &Tgz{Base: base}
-	case "zstd":
-		ctx = &Zstd{Base: base}
-	default:
-		ctx = &Zstd{Base: base}
+// Run compressor, packs the dump path of model into a compressed tar. It
+// stops when runCtx is done or the compress_with timeout expired.
+func Run(runCtx context.Context, model config.ModelConfig) (archivePath string, err error) {
+	ctx, err := newContext(model)
+	if err != nil {
+		return
 	}
 
-	slog.Info("starting compression", 
+	slog.Info("starting compression",
 		"component", "compressor",
 		"model", model.Name,
 		"type", model.CompressWith.Type)
 
-	// set workdir
-	os.Chdir(path.Join(model.DumpPath, "../"))
-	archivePath, err = ctx.perform()
-	if err != nil {
-		return
+	filePath := archiveFilePath(model, ctx.ext())
+	if helper.IsDryRun(runCtx) {
+		slog.Info("Dry run, compression skipped",
+			"component", "compressor",
+			"model", model.Name,
+			"source", model.DumpPath,
+			"archivePath", filePath)
+		return filePath, nil
+	}
+
+	runCtx, cancel := helper.WithTimeout(runCtx, "compress", model.CompressWith.Timeout)
+	defer cancel()
+	if err = compress(runCtx, ctx, model.DumpPath, filePath); err != nil {
+		os.Remove(filePath)
+		return "", err
 	}
-	slog.Info("compression completed", 
+	archivePath = filePath
+
+	slog.Info("compression completed",
 		"component", "compressor",
 		"model", model.Name,
 		"type", model.CompressWith.Type,
@@ -68,3 +128,84 @@ func Run(model config.ModelConfig) (archivePath string, err error) {
 
 	return
 }
+
+func compress(runCtx context.Context, ctx Context, srcDir, filePath string) (err error) {
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	cw, err := ctx.newWriter(runCtx, helper.NewContextWriter(runCtx, file))
+	if err != nil {
+		return err
+	}
+	if err = writeTar(cw, srcDir); err != nil {
+		cw.Close()
+		return err
+	}
+	if err = cw.Close(); err != nil {
+		return fmt.Errorf("finish compression failed: %s", err)
+	}
+	return file.Close()
+}
+
+// contextForFile picks the compressor by the extension of filePath, the
+// compressor of model first
+func contextForFile(model config.ModelConfig, filePath string) (Context, error) {
+	if ctx, err := newContext(model); err == nil && strings.HasSuffix(filePath, ctx.ext()) {
+		return ctx, nil
+	}
+	// the longest extension wins, .tar.gz is picked over .tar
+	var found Context
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for _, fn := range registry {
+		ctx, err := fn(Base{})
+		if err != nil || !strings.HasSuffix(filePath, ctx.ext()) {
+			continue
+		}
+		if found == nil || len(ctx.ext()) > len(found.ext()) {
+			found = ctx
+		}
+	}
+	if found != nil {
+		return found, nil
+	}
+	return nil, fmt.Errorf("unknown compression format of %s", filePath)
+}
+
+// Extract unpacks a compressed backup of model into destDir, the format is
+// picked by the file extension.
+func Extract(runCtx context.Context, model config.ModelConfig, archivePath, destDir string) error {
+	slog.Info("Extracting archive",
+		"component", "compressor",
+		"archivePath", archivePath,
+		"destination", destDir)
+
+	ctx, err := contextForFile(model, archivePath)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	cr, err := ctx.newReader(runCtx, helper.NewContextReader(runCtx, file))
+	if err != nil {
+		return fmt.Errorf("extract %s failed: %s", archivePath, err)
+	}
+	defer cr.Close()
+
+	helper.MkdirP(destDir)
+	if err = readTar(cr, destDir); err != nil {
+		return fmt.Errorf("extract %s failed: %s", archivePath, err)
+	}
+	if err = joinParts(destDir); err != nil {
+		return fmt.Errorf("extract %s failed: %s", archivePath, err)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package compressor
+
+import (
+	"context"
+	"io"
+
+	"github.com/dsnet/compress/bzip2"
+
+	"github.com/holgerhuo/gobackup/config"
+)
+
+// Bzip2 .tar.bz2 compressor
+//
+// type: bzip2
+// level: 9 # 1 - 9
+type Bzip2 struct {
+	Base
+}
+
+func init() {
+	register("bzip2", func(base Base) (Context, error) {
+		return &Bzip2{Base: base}, nil
+	}, config.Schema{Optional: []string{"level"}})
+}
+
+func (ctx *Bzip2) ext() string {
+	return ".tar.bz2"
+}
+
+func (ctx *Bzip2) newWriter(runCtx context.Context, w io.Writer) (io.WriteCloser, error) {
+	return bzip2.NewWriter(w, &bzip2.WriterConfig{Level: ctx.level})
+}
+
+func (ctx *Bzip2) newReader(runCtx context.Context, r io.Reader) (io.ReadCloser, error) {
+	return bzip2.NewReader(r, nil)
+}
@@ -0,0 +1,84 @@
+package compressor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/holgerhuo/gobackup/config"
+)
+
+// Compressor is a compressor type added with Register, ctx is done when
+// the run is cancelled or the timeout of compress_with expired
+type Compressor interface {
+	// Ext of the compressed tar, example: .tar.br
+	Ext() string
+	// NewWriter compresses everything written into w, closing it must not
+	// close w
+	NewWriter(ctx context.Context, w io.Writer) (io.WriteCloser, error)
+	// NewReader decompresses r
+	NewReader(ctx context.Context, r io.Reader) (io.ReadCloser, error)
+}
+
+// Factory creates the Compressor of model from the keys of compress_with
+type Factory func(model string, settings map[string]interface{}) (Compressor, error)
+
+// newContextFunc creates the Context of a compressor type
+type newContextFunc func(base Base) (Context, error)
+
+var (
+	registryMu sync.RWMutex
+	// registry of compressor types, the built in types register from init
+	registry = map[string]newContextFunc{}
+)
+
+// register adds a compressor type with its config schema
+func register(typ string, fn newContextFunc, schema config.Schema) {
+	config.RegisterSchema(config.SectionCompressor, typ, schema)
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[typ] = fn
+}
+
+// Register adds a compressor type, usable as `type: typ` in the config.
+// It panics when typ is taken, schema lists the keys the type accepts.
+func Register(typ string, factory Factory, schema config.Schema) {
+	if factory == nil {
+		panic("compressor: Register factory is nil")
+	}
+	register(typ, func(base Base) (Context, error) {
+		return newExternal(base, factory)
+	}, schema)
+}
+
+// external runs a Compressor added with Register or found as plugin
+type external struct {
+	Base
+	compressor Compressor
+}
+
+func newExternal(base Base, factory Factory) (Context, error) {
+	var settings map[string]interface{}
+	if base.viper != nil {
+		settings = base.viper.AllSettings()
+	}
+	compressor, err := factory(base.model.Name, settings)
+	if err != nil {
+		return nil, fmt.Errorf("model: %s compress_with: %s", base.model.Name, err)
+	}
+	return &external{Base: base, compressor: compressor}, nil
+}
+
+func (ctx *external) ext() string {
+	return ctx.compressor.Ext()
+}
+
+func (ctx *external) newWriter(runCtx context.Context, w io.Writer) (io.WriteCloser, error) {
+	return ctx.compressor.NewWriter(runCtx, w)
+}
+
+func (ctx *external) newReader(runCtx context.Context, r io.Reader) (io.ReadCloser, error) {
+	return ctx.compressor.NewReader(runCtx, r)
+}
@@ -0,0 +1,50 @@
+package compressor
+
+import (
+	"context"
+	"io"
+
+	"github.com/pierrec/lz4/v4"
+
+	"github.com/holgerhuo/gobackup/config"
+)
+
+// Lz4 .tar.lz4 compressor, fastest and largest output
+//
+// type: lz4
+// level: 0 # 0 (fast) - 9
+// threads: 4 # default to the number of CPUs
+type Lz4 struct {
+	Base
+}
+
+func init() {
+	register("lz4", func(base Base) (Context, error) {
+		return &Lz4{Base: base}, nil
+	}, config.Schema{Optional: []string{"level", "threads"}})
+}
+
+func (ctx *Lz4) ext() string {
+	return ".tar.lz4"
+}
+
+func (ctx *Lz4) newWriter(runCtx context.Context, w io.Writer) (io.WriteCloser, error) {
+	level := lz4.Fast
+	if ctx.level > 0 && ctx.level <= 9 {
+		level = lz4.CompressionLevel(1 << (8 + ctx.level))
+	}
+
+	lw := lz4.NewWriter(w)
+	err := lw.Apply(
+		lz4.CompressionLevelOption(level),
+		lz4.ConcurrencyOption(ctx.threads),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return lw, nil
+}
+
+func (ctx *Lz4) newReader(runCtx context.Context, r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(lz4.NewReader(r)), nil
+}
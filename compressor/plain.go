@@ -0,0 +1,39 @@
+package compressor
+
+import (
+	"context"
+	"io"
+
+	"github.com/holgerhuo/gobackup/config"
+)
+
+// Tar .tar without compression
+//
+// type: tar
+type Tar struct {
+	Base
+}
+
+func init() {
+	register("tar", func(base Base) (Context, error) {
+		return &Tar{Base: base}, nil
+	}, config.Schema{})
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func (ctx *Tar) ext() string {
+	return ".tar"
+}
+
+func (ctx *Tar) newWriter(runCtx context.Context, w io.Writer) (io.WriteCloser, error) {
+	return nopWriteCloser{w}, nil
+}
+
+func (ctx *Tar) newReader(runCtx context.Context, r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(r), nil
+}
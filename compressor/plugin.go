@@ -0,0 +1,46 @@
+package compressor
+
+import (
+	"context"
+	"io"
+
+	"github.com/holgerhuo/gobackup/plugin"
+)
+
+// pluginCompressor runs a compressor type of an exec plugin, the plugin
+// describes the ext
+type pluginCompressor struct {
+	plugin   *plugin.Plugin
+	model    string
+	settings map[string]interface{}
+	ext      string
+}
+
+func pluginFactory(p *plugin.Plugin) Factory {
+	return func(model string, settings map[string]interface{}) (Compressor, error) {
+		desc, err := p.Describe()
+		if err != nil {
+			return nil, err
+		}
+		return &pluginCompressor{plugin: p, model: model, settings: settings, ext: desc.Ext}, nil
+	}
+}
+
+func (c *pluginCompressor) request(method string) plugin.Request {
+	req := c.plugin.NewRequest(method)
+	req.Model = c.model
+	req.Settings = c.settings
+	return req
+}
+
+func (c *pluginCompressor) Ext() string {
+	return c.ext
+}
+
+func (c *pluginCompressor) NewWriter(ctx context.Context, w io.Writer) (io.WriteCloser, error) {
+	return c.plugin.NewWriter(ctx, c.request("compress"), w), nil
+}
+
+func (c *pluginCompressor) NewReader(ctx context.Context, r io.Reader) (io.ReadCloser, error) {
+	return c.plugin.NewReader(ctx, c.request("decompress"), r), nil
+}
@@ -0,0 +1,213 @@
+package compressor
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/holgerhuo/gobackup/config"
+	"github.com/holgerhuo/gobackup/helper"
+)
+
+var (
+	// partRegexp matches the chunks of a streamed entry, example: app.sql.part-00001,
+	// the index grows past 5 digits for more than 99999 parts
+	partRegexp = regexp.MustCompile(`^(.+)\.part-(\d{5,})$`)
+)
+
+// FileName of the compressed backup of model, example: app.2006.01.02.15.04.05.tar.zst
+func FileName(model config.ModelConfig) (string, error) {
+	ctx, err := newContext(model)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Base(archiveFilePath(model, ctx.ext())), nil
+}
+
+// NewWriter compresses everything written into w with the compressor of
+// model, writing fails once runCtx is done
+func NewWriter(runCtx context.Context, model config.ModelConfig, w io.Writer) (io.WriteCloser, error) {
+	ctx, err := newContext(model)
+	if err != nil {
+		return nil, err
+	}
+	return ctx.newWriter(runCtx, helper.NewContextWriter(runCtx, w))
+}
+
+// TarWriter packs streams of unknown size into a tar, entries are safe to
+// write from several goroutines.
+type TarWriter struct {
+	mu        sync.Mutex
+	tw        *tar.Writer
+	prefix    string
+	chunkSize int
+}
+
+// NewTarWriter names every entry under prefix, streams bigger than chunkSize
+// are split into parts that Extract joins again.
+func NewTarWriter(w io.Writer, prefix string, chunkSize int) *TarWriter {
+	return &TarWriter{
+		tw:        tar.NewWriter(w),
+		prefix:    prefix,
+		chunkSize: chunkSize,
+	}
+}
+
+// Entry opens a writer for name. Data is kept in memory up to one chunk and
+// written as name, or as name.part-00000, name.part-00001 ... when bigger.
+func (t *TarWriter) Entry(name string) (io.WriteCloser, error) {
+	return &entryWriter{
+		tar:  t,
+		name: path.Join(t.prefix, name),
+	}, nil
+}
+
+// Close finishes the tar, every entry must be closed before
+func (t *TarWriter) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.tw.Close()
+}
+
+func (t *TarWriter) writeFile(name string, data []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     int64(len(data)),
+		Mode:     0600,
+		ModTime:  time.Now(),
+	}
+	if err := t.tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("add %s: %w", name, err)
+	}
+	if _, err := t.tw.Write(data); err != nil {
+		return fmt.Errorf("add %s: %w", name, err)
+	}
+	return nil
+}
+
+type entryWriter struct {
+	tar  *TarWriter
+	name string
+	buf  bytes.Buffer
+	part int
+}
+
+func (e *entryWriter) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		room := e.tar.chunkSize - e.buf.Len()
+		if room > len(p) {
+			room = len(p)
+		}
+		e.buf.Write(p[:room])
+		p = p[room:]
+		n += room
+
+		if e.buf.Len() >= e.tar.chunkSize {
+			if err = e.flushPart(); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+func (e *entryWriter) flushPart() error {
+	err := e.tar.writeFile(fmt.Sprintf("%s.part-%05d", e.name, e.part), e.buf.Bytes())
+	e.buf.Reset()
+	e.part++
+	return err
+}
+
+func (e *entryWriter) Close() error {
+	if e.part == 0 {
+		return e.tar.writeFile(e.name, e.buf.Bytes())
+	}
+	if e.buf.Len() == 0 {
+		return nil
+	}
+	return e.flushPart()
+}
+
+// joinParts concatenates the parts of streamed entries under dir back into
+// the original files.
+func joinParts(dir string) error {
+	parts := map[string][]filePart{}
+	err := filepath.Walk(dir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if match := partRegexp.FindStringSubmatch(filePath); info.Mode().IsRegular() && match != nil {
+			index, err := strconv.Atoi(match[2])
+			if err != nil {
+				return fmt.Errorf("part %s: %w", filePath, err)
+			}
+			parts[match[1]] = append(parts[match[1]], filePart{index: index, path: filePath})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for target, fileParts := range parts {
+		// by index, names don't sort past part-99999
+		sort.Slice(fileParts, func(i, j int) bool {
+			return fileParts[i].index < fileParts[j].index
+		})
+		files := make([]string, len(fileParts))
+		for i, part := range fileParts {
+			if part.index != i {
+				return fmt.Errorf("join %s: part %d is missing", strings.TrimPrefix(target, dir), i)
+			}
+			files[i] = part.path
+		}
+		if err := concatFiles(target, files); err != nil {
+			return fmt.Errorf("join %s: %w", strings.TrimPrefix(target, dir), err)
+		}
+	}
+	return nil
+}
+
+type filePart struct {
+	index int
+	path  string
+}
+
+func concatFiles(target string, files []string) error {
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	for _, filePath := range files {
+		in, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(out, in)
+		in.Close()
+		if err != nil {
+			return err
+		}
+		if err = os.Remove(filePath); err != nil {
+			return err
+		}
+	}
+	return out.Close()
+}
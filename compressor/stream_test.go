@@ -0,0 +1,152 @@
+package compressor
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestTarWriterParts(t *testing.T) {
+	const chunkSize = 10
+
+	cases := []struct {
+		name      string
+		data      string
+		wantNames []string
+	}{
+		{name: "empty", data: "", wantNames: []string{"m/db/empty"}},
+		{name: "below chunk", data: "123456789", wantNames: []string{"m/db/below chunk"}},
+		{name: "exactly one chunk", data: "0123456789", wantNames: []string{"m/db/exactly one chunk.part-00000"}},
+		{
+			name:      "several chunks",
+			data:      "0123456789abcdefghijXY",
+			wantNames: []string{"m/db/several chunks.part-00000", "m/db/several chunks.part-00001", "m/db/several chunks.part-00002"},
+		},
+		{
+			name:      "whole chunks",
+			data:      "0123456789abcdefghij",
+			wantNames: []string{"m/db/whole chunks.part-00000", "m/db/whole chunks.part-00001"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			tw := NewTarWriter(buf, "m", chunkSize)
+			w, err := tw.Entry("db/" + c.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// uneven writes cross the chunk boundaries
+			for data := c.data; len(data) > 0; {
+				n := min(len(data), 3)
+				if _, err = io.WriteString(w, data[:n]); err != nil {
+					t.Fatal(err)
+				}
+				data = data[n:]
+			}
+			if err = w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if err = tw.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			var names []string
+			var joined strings.Builder
+			tr := tar.NewReader(bytes.NewReader(buf.Bytes()))
+			for {
+				header, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if header.Size > chunkSize {
+					t.Errorf("%s has %d bytes, more than a chunk", header.Name, header.Size)
+				}
+				names = append(names, header.Name)
+				io.Copy(&joined, tr)
+			}
+			if !reflect.DeepEqual(names, c.wantNames) {
+				t.Errorf("entries = %v, want %v", names, c.wantNames)
+			}
+			if joined.String() != c.data {
+				t.Errorf("data = %q, want %q", joined.String(), c.data)
+			}
+
+			destDir := t.TempDir()
+			if err = readTar(bytes.NewReader(buf.Bytes()), destDir); err != nil {
+				t.Fatal(err)
+			}
+			if err = joinParts(destDir); err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(filepath.Join(destDir, "m", "db", c.name))
+			if err != nil || string(got) != c.data {
+				t.Errorf("joined file = %q, %v, want %q", got, err, c.data)
+			}
+			if matches, _ := filepath.Glob(filepath.Join(destDir, "m", "db", "*.part-*")); len(matches) > 0 {
+				t.Errorf("parts left after join: %v", matches)
+			}
+		})
+	}
+}
+
+func TestJoinPartsOrder(t *testing.T) {
+	if testing.Short() {
+		t.Skip("writes 100001 parts")
+	}
+
+	dir := t.TempDir()
+	const parts = 100001
+	var want strings.Builder
+	for i := 0; i < parts; i++ {
+		data := fmt.Sprintf("%d\n", i)
+		want.WriteString(data)
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("app.sql.part-%05d", i)), []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := joinParts(dir); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "app.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want.String() {
+		t.Errorf("part-100000 and later aren't joined in order")
+	}
+}
+
+func TestJoinPartsMissing(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"app.sql.part-00000", "app.sql.part-00002", "other.sql"} {
+		os.WriteFile(filepath.Join(dir, name), []byte(name), 0600)
+	}
+
+	err := joinParts(dir)
+	if err == nil || !strings.Contains(err.Error(), "part 1 is missing") {
+		t.Fatalf("joinParts() error = %v, want missing part", err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	if want := []string{"app.sql.part-00000", "app.sql.part-00002", "other.sql"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("files = %v, want %v untouched", names, want)
+	}
+}
@@ -0,0 +1,137 @@
+package compressor
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/holgerhuo/gobackup/helper"
+)
+
+// writeTar writes srcDir into w as a tar stream, entries are prefixed with
+// the base name of srcDir, like `tar -cf - name` run from its parent.
+// srcDir only holds the dumps of the model, an unreadable file fails it.
+func writeTar(w io.Writer, srcDir string) error {
+	tw := tar.NewWriter(w)
+	parent := filepath.Dir(srcDir)
+
+	err := filepath.WalkDir(srcDir, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(parent, filePath)
+		if err != nil {
+			return err
+		}
+		if err := helper.AddTarEntry(tw, filePath, name, d); err != nil {
+			return fmt.Errorf("add %s: %w", filePath, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+// readTar extracts the tar stream r into destDir. Entries escaping destDir,
+// or written through a symlink, are rejected. Symlinks pointing outside of
+// destDir are skipped with a warning.
+func readTar(r io.Reader, destDir string) error {
+	tr := tar.NewReader(r)
+	destDir = filepath.Clean(destDir)
+
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(destDir, filepath.FromSlash(header.Name))
+		if !isWithin(destDir, target) {
+			return fmt.Errorf("entry %s is outside of %s", header.Name, destDir)
+		}
+		if err := checkNoSymlink(destDir, target); err != nil {
+			return fmt.Errorf("extract %s: %w", header.Name, err)
+		}
+
+		if err := extractTarEntry(tr, header, destDir, target); err != nil {
+			return fmt.Errorf("extract %s: %w", header.Name, err)
+		}
+	}
+}
+
+// isWithin tells whether target is dir or below it, both must be clean
+func isWithin(dir, target string) bool {
+	return target == dir || strings.HasPrefix(target, dir+string(os.PathSeparator))
+}
+
+// checkNoSymlink fails when target or any directory between destDir and
+// target is a symlink, so an entry can't be written outside of destDir
+// through a link extracted before it.
+func checkNoSymlink(destDir, target string) error {
+	rel, err := filepath.Rel(destDir, target)
+	if err != nil || rel == "." {
+		return err
+	}
+	current := destDir
+	for _, part := range strings.Split(rel, string(os.PathSeparator)) {
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return fmt.Errorf("%s is a symlink", current)
+		}
+	}
+	return nil
+}
+
+func extractTarEntry(tr *tar.Reader, header *tar.Header, destDir, target string) error {
+	switch header.Typeflag {
+	case tar.TypeDir:
+		return os.MkdirAll(target, 0700)
+	case tar.TypeSymlink:
+		link := filepath.FromSlash(header.Linkname)
+		if filepath.IsAbs(link) || !isWithin(destDir, filepath.Join(filepath.Dir(target), link)) {
+			slog.Warn("Skipped symlink pointing outside of the restore directory",
+				"component", "compressor",
+				"path", header.Name,
+				"link", header.Linkname)
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+			return err
+		}
+		return os.Symlink(header.Linkname, target)
+	case tar.TypeReg:
+		if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+			return err
+		}
+		file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, header.FileInfo().Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(file, tr); err != nil {
+			file.Close()
+			return err
+		}
+		return file.Close()
+	}
+	// devices, fifos and hard links are never written by gobackup
+	return nil
+}
@@ -0,0 +1,151 @@
+package compressor
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+type tarEntry struct {
+	name string
+	link string
+	body string
+	dir  bool
+}
+
+func buildTar(t *testing.T, entries []tarEntry) *bytes.Buffer {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Mode: 0600, Typeflag: tar.TypeReg, Size: int64(len(entry.body))}
+		switch {
+		case entry.dir:
+			header.Typeflag, header.Mode, header.Size = tar.TypeDir, 0700, 0
+		case len(entry.link) > 0:
+			header.Typeflag, header.Linkname, header.Size = tar.TypeSymlink, entry.link, 0
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(entry.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func TestReadTar(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on windows")
+	}
+
+	cases := []struct {
+		name    string
+		entries []tarEntry
+		wantErr string
+		files   map[string]string
+		missing []string
+	}{
+		{
+			name:    "plain files",
+			entries: []tarEntry{{name: "m/", dir: true}, {name: "m/a.sql", body: "a"}, {name: "m/b/c.txt", body: "c"}},
+			files:   map[string]string{"m/a.sql": "a", "m/b/c.txt": "c"},
+		},
+		{
+			name:    "dot dot escape",
+			entries: []tarEntry{{name: "../evil", body: "x"}},
+			wantErr: "outside of",
+		},
+		{
+			name:    "nested dot dot escape",
+			entries: []tarEntry{{name: "m/../../evil", body: "x"}},
+			wantErr: "outside of",
+		},
+		{
+			name:    "relative symlink inside",
+			entries: []tarEntry{{name: "m/a.sql", body: "a"}, {name: "m/link", link: "a.sql"}},
+			files:   map[string]string{"m/a.sql": "a", "m/link": "a"},
+		},
+		{
+			name:    "absolute symlink skipped",
+			entries: []tarEntry{{name: "m/link", link: "/etc"}},
+			missing: []string{"m/link"},
+		},
+		{
+			name:    "escaping symlink skipped",
+			entries: []tarEntry{{name: "m/link", link: "../../outside"}},
+			missing: []string{"m/link"},
+		},
+		{
+			name: "write through symlink dir",
+			entries: []tarEntry{
+				{name: "m/sub/", dir: true},
+				{name: "m/link", link: "sub"},
+				{name: "m/link/file", body: "x"},
+			},
+			wantErr: "is a symlink",
+		},
+		{
+			name:    "overwrite symlink",
+			entries: []tarEntry{{name: "m/a.sql", body: "a"}, {name: "m/link", link: "a.sql"}, {name: "m/link", body: "x"}},
+			wantErr: "is a symlink",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			destDir := filepath.Join(t.TempDir(), "dest")
+			if err := os.MkdirAll(destDir, 0700); err != nil {
+				t.Fatal(err)
+			}
+
+			err := readTar(buildTar(t, c.entries), destDir)
+			if len(c.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("readTar() error = %v, want %q", err, c.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readTar() error = %v", err)
+			}
+			for name, want := range c.files {
+				got, err := os.ReadFile(filepath.Join(destDir, name))
+				if err != nil || string(got) != want {
+					t.Errorf("%s = %q, %v, want %q", name, got, err, want)
+				}
+			}
+			for _, name := range c.missing {
+				if _, err := os.Lstat(filepath.Join(destDir, name)); !os.IsNotExist(err) {
+					t.Errorf("%s exists, want skipped", name)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteTarUnreadable(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Getuid() == 0 {
+		t.Skip("file permissions aren't enforced")
+	}
+
+	srcDir := filepath.Join(t.TempDir(), "model")
+	if err := os.MkdirAll(srcDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(srcDir, "ok.sql"), []byte("ok"), 0600)
+	os.WriteFile(filepath.Join(srcDir, "secret.sql"), []byte("no"), 0000)
+
+	// a dump that can't be read must fail the backup
+	if err := writeTar(&bytes.Buffer{}, srcDir); err == nil || !strings.Contains(err.Error(), "secret.sql") {
+		t.Fatalf("writeTar() error = %v, want secret.sql unreadable", err)
+	}
+}
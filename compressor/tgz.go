@@ -1,36 +1,52 @@
 package compressor
 
 import (
-	"github.com/holgerhuo/gobackup/helper"
+	"context"
+	"io"
+
+	"github.com/klauspost/pgzip"
+
+	"github.com/holgerhuo/gobackup/config"
 )
 
-// Tgz .tar.gz compressor
+// Tgz .tar.gz compressor, gzip blocks are compressed in parallel
 //
 // type: tgz
+// level: 6 # 0 (no compression) - 9
+// threads: 4 # default to the number of CPUs
 type Tgz struct {
 	Base
 }
 
-func (ctx *Tgz) perform() (archivePath string, err error) {
-	filePath := ctx.archiveFilePath(".tar.gz")
+func init() {
+	register("tgz", func(base Base) (Context, error) {
+		return &Tgz{Base: base}, nil
+	}, config.Schema{Optional: []string{"level", "threads"}})
+}
 
-	opts := ctx.options()
-	opts = append(opts, filePath)
-	opts = append(opts, ctx.name)
+func (ctx *Tgz) ext() string {
+	return ".tar.gz"
+}
 
-	_, err = helper.Exec("tar", opts...)
-	if err == nil {
-		archivePath = filePath
-		return
+// gzipLevel is the configured level, a configured 0 means no compression
+func (ctx *Tgz) gzipLevel() int {
+	if ctx.levelSet {
+		return ctx.level
 	}
-	return
+	return pgzip.DefaultCompression
 }
 
-func (ctx *Tgz) options() (opts []string) {
-	if helper.IsGnuTar {
-		opts = append(opts, "--ignore-failed-read")
+func (ctx *Tgz) newWriter(runCtx context.Context, w io.Writer) (io.WriteCloser, error) {
+	gw, err := pgzip.NewWriterLevel(w, ctx.gzipLevel())
+	if err != nil {
+		return nil, err
+	}
+	if err = gw.SetConcurrency(1<<20, ctx.threads); err != nil {
+		return nil, err
 	}
-	opts = append(opts, "-zcf")
+	return gw, nil
+}
 
-	return
+func (ctx *Tgz) newReader(runCtx context.Context, r io.Reader) (io.ReadCloser, error) {
+	return pgzip.NewReader(r)
 }
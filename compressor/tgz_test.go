@@ -0,0 +1,39 @@
+package compressor
+
+import (
+	"testing"
+
+	"github.com/holgerhuo/gobackup/config"
+	"github.com/klauspost/pgzip"
+	"github.com/spf13/viper"
+)
+
+func TestTgzLevel(t *testing.T) {
+	cases := []struct {
+		name  string
+		level interface{}
+		want  int
+	}{
+		{name: "unset", level: nil, want: pgzip.DefaultCompression},
+		{name: "level 0", level: 0, want: pgzip.NoCompression},
+		{name: "level 9", level: 9, want: pgzip.BestCompression},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			v := viper.New()
+			v.Set("type", "tgz")
+			if c.level != nil {
+				v.Set("level", c.level)
+			}
+			model := config.ModelConfig{CompressWith: config.SubConfig{Type: "tgz", Viper: v}}
+			ctx, err := newContext(model)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ctx.(*Tgz).gzipLevel(); got != c.want {
+				t.Errorf("gzipLevel() = %d, want %d", got, c.want)
+			}
+		})
+	}
+}
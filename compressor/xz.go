@@ -0,0 +1,56 @@
+package compressor
+
+import (
+	"context"
+	"io"
+
+	"github.com/ulikunitz/xz"
+
+	"github.com/holgerhuo/gobackup/config"
+)
+
+var (
+	// xzDictCaps dictionary sizes of the xz presets 0 - 9
+	xzDictCaps = []int{256 << 10, 1 << 20, 2 << 20, 4 << 20, 4 << 20, 8 << 20, 8 << 20, 16 << 20, 32 << 20, 64 << 20}
+)
+
+// Xz .tar.xz compressor, smallest output and slowest
+//
+// type: xz
+// level: 6 # 0 - 9, picks the dictionary size like the xz presets
+type Xz struct {
+	Base
+}
+
+func init() {
+	register("xz", func(base Base) (Context, error) {
+		return &Xz{Base: base}, nil
+	}, config.Schema{Optional: []string{"level"}})
+}
+
+func (ctx *Xz) ext() string {
+	return ".tar.xz"
+}
+
+// writerConfig picks the dictionary of level, level 0 is the smallest one
+// when configured
+func (ctx *Xz) writerConfig() xz.WriterConfig {
+	config := xz.WriterConfig{}
+	if ctx.levelSet && ctx.level >= 0 && ctx.level < len(xzDictCaps) {
+		config.DictCap = xzDictCaps[ctx.level]
+	}
+	return config
+}
+
+func (ctx *Xz) newWriter(runCtx context.Context, w io.Writer) (io.WriteCloser, error) {
+	config := ctx.writerConfig()
+	return config.NewWriter(w)
+}
+
+func (ctx *Xz) newReader(runCtx context.Context, r io.Reader) (io.ReadCloser, error) {
+	xr, err := xz.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(xr), nil
+}
@@ -0,0 +1,40 @@
+package compressor
+
+import (
+	"testing"
+
+	"github.com/holgerhuo/gobackup/config"
+	"github.com/spf13/viper"
+)
+
+func TestXzLevel(t *testing.T) {
+	cases := []struct {
+		name    string
+		level   interface{}
+		dictCap int
+	}{
+		{name: "unset", level: nil, dictCap: 0},
+		{name: "level 0", level: 0, dictCap: 256 << 10},
+		{name: "level 6", level: 6, dictCap: 8 << 20},
+		{name: "level 9", level: 9, dictCap: 64 << 20},
+		{name: "out of range", level: 12, dictCap: 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			v := viper.New()
+			v.Set("type", "xz")
+			if c.level != nil {
+				v.Set("level", c.level)
+			}
+			model := config.ModelConfig{CompressWith: config.SubConfig{Type: "xz", Viper: v}}
+			ctx, err := newContext(model)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ctx.(*Xz).writerConfig().DictCap; got != c.dictCap {
+				t.Errorf("DictCap = %d, want %d", got, c.dictCap)
+			}
+		})
+	}
+}
@@ -1,36 +1,47 @@
 package compressor
 
 import (
-	"github.com/holgerhuo/gobackup/helper"
+	"context"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/holgerhuo/gobackup/config"
 )
 
 // Zstd .tar.zst compressor
 //
 // type: zstd
+// level: 3 # 1 - 22, mapped to the nearest level of the Go encoder
+// threads: 4 # default to the number of CPUs
 type Zstd struct {
 	Base
 }
 
-func (ctx *Zstd) perform() (archivePath string, err error) {
-	filePath := ctx.archiveFilePath(".tar.zst")
+func init() {
+	register("zstd", func(base Base) (Context, error) {
+		return &Zstd{Base: base}, nil
+	}, config.Schema{Optional: []string{"level", "threads"}})
+}
 
-	opts := ctx.options()
-	opts = append(opts, filePath)
-	opts = append(opts, ctx.name)
+func (ctx *Zstd) ext() string {
+	return ".tar.zst"
+}
 
-	_, err = helper.Exec("tar", opts...)
-	if err == nil {
-		archivePath = filePath
-		return
+func (ctx *Zstd) newWriter(runCtx context.Context, w io.Writer) (io.WriteCloser, error) {
+	opts := []zstd.EOption{
+		zstd.WithEncoderConcurrency(ctx.threads),
+	}
+	if ctx.level != 0 {
+		opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(ctx.level)))
 	}
-	return
+	return zstd.NewWriter(w, opts...)
 }
 
-func (ctx *Zstd) options() (opts []string) {
-	if helper.IsGnuTar {
-		opts = append(opts, "--ignore-failed-read")
+func (ctx *Zstd) newReader(runCtx context.Context, r io.Reader) (io.ReadCloser, error) {
+	zr, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
 	}
-	opts = append(opts, "-acf")
-
-	return
+	return zr.IOReadCloser(), nil
 }
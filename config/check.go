@@ -0,0 +1,272 @@
+package config
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+var (
+	// sizeRegexp matches the sizes viper reads, example: 64MB, 512 kb, 1024
+	sizeRegexp = regexp.MustCompile(`(?i)^\s*[1-9]\d*(\s*[kmg]?b)?\s*$`)
+)
+
+// Problem found in config by Check
+type Problem struct {
+	// Path of the key in YAML, example: models.demo.store_with.bucket
+	Path    string
+	Message string
+}
+
+func (p Problem) String() string {
+	return p.Path + ": " + p.Message
+}
+
+// Check validates every model of the config against the schemas of
+// its databases, storages, compressor, encryptor and archive, and reports
+// missing required keys, unsupported types and unknown keys. A config read
+// by LoadUnresolved also has the syntax of its secret references checked.
+func (cfg *Config) Check() (problems []Problem) {
+	models, ok := cfg.viper.Get("models").(map[string]interface{})
+	if !ok {
+		return []Problem{{Path: "models", Message: "is required"}}
+	}
+
+	problems = append(problems, checkConcurrency("", cfg.viper.AllSettings())...)
+	for _, name := range sortedKeys(models) {
+		problems = append(problems, checkModel("models."+name, models[name])...)
+	}
+	if cfg.unresolved {
+		problems = append(problems, checkReferences(cfg.viper.AllSettings(), "")...)
+	}
+	return
+}
+
+func checkModel(keyPath string, value interface{}) (problems []Problem) {
+	model, ok := value.(map[string]interface{})
+	if !ok {
+		return []Problem{{Path: keyPath, Message: "must be a map"}}
+	}
+
+	problems = append(problems, checkKeys(keyPath, model, modelSchema)...)
+
+	if _, ok := model["store_with"]; !ok {
+		if _, ok := model["storages"]; !ok {
+			problems = append(problems, Problem{Path: keyPath, Message: "store_with or storages is required"})
+		}
+	}
+	if value, ok := model["store_with"]; ok {
+		problems = append(problems, checkTyped(keyPath+".store_with", value, SectionStorages, false, "keep", "upload_timeout", "retries", "backoff", "max_backoff")...)
+	}
+	problems = append(problems, checkEach(keyPath+".storages", model["storages"], SectionStorages, "keep", "upload_timeout", "retries", "backoff", "max_backoff")...)
+	problems = append(problems, checkEach(keyPath+".databases", model["databases"], SectionDatabases, "timeout", "retries", "backoff", "max_backoff")...)
+
+	if value, ok := model["compress_with"]; ok {
+		problems = append(problems, checkTyped(keyPath+".compress_with", value, SectionCompressor, true, "timeout")...)
+	}
+	if value, ok := model["encrypt_with"]; ok {
+		problems = append(problems, checkTyped(keyPath+".encrypt_with", value, SectionEncryptor, false, "timeout")...)
+	}
+	if value, ok := model["archive"]; ok {
+		section, ok := value.(map[string]interface{})
+		if !ok {
+			problems = append(problems, Problem{Path: keyPath + ".archive", Message: "must be a map"})
+		} else {
+			problems = append(problems, checkKeys(keyPath+".archive", section, archiveSchema)...)
+			problems = append(problems, checkDuration(keyPath+".archive", section, "timeout")...)
+		}
+	}
+
+	if policy, ok := model["storage_policy"]; ok && policy != "all" && policy != "any" {
+		problems = append(problems, Problem{Path: keyPath + ".storage_policy", Message: "must be all or any"})
+	}
+
+	problems = append(problems, checkSchedule(keyPath, model)...)
+	problems = append(problems, checkConcurrency(keyPath+".", model)...)
+	if value, ok := model["stream_chunk_size"]; ok && !isSize(value) {
+		problems = append(problems, Problem{Path: keyPath + ".stream_chunk_size", Message: "must be a size like 64MB"})
+	}
+	if value, ok := model["temp_files"]; ok {
+		if _, ok := value.(bool); !ok {
+			problems = append(problems, Problem{Path: keyPath + ".temp_files", Message: "must be true or false"})
+		}
+	}
+
+	return
+}
+
+// checkEach checks every entry of a named map like databases or storages
+func checkEach(keyPath string, value interface{}, section string, common ...string) (problems []Problem) {
+	if value == nil {
+		return nil
+	}
+	entries, ok := value.(map[string]interface{})
+	if !ok {
+		return []Problem{{Path: keyPath, Message: "must be a map"}}
+	}
+	for _, name := range sortedKeys(entries) {
+		problems = append(problems, checkTyped(keyPath+"."+name, entries[name], section, false, common...)...)
+	}
+	return
+}
+
+// checkTyped checks an entry of section picking its schema by the `type`
+// key, the common keys are accepted by every type.
+func checkTyped(keyPath string, value interface{}, section string, optionalType bool, common ...string) []Problem {
+	entry, ok := value.(map[string]interface{})
+	if !ok {
+		return []Problem{{Path: keyPath, Message: "must be a map"}}
+	}
+
+	// the stage timeouts are common keys, scp and ftp have their own timeout
+	var problems []Problem
+	for _, key := range common {
+		switch {
+		case strings.HasSuffix(key, "timeout"), strings.HasSuffix(key, "backoff"):
+			problems = append(problems, checkDuration(keyPath, entry, key)...)
+		case key == "retries", key == "keep":
+			problems = append(problems, checkCount(keyPath, entry, key)...)
+		}
+	}
+
+	typ, _ := entry["type"].(string)
+	if len(typ) == 0 {
+		if optionalType {
+			return problems
+		}
+		return append(problems, Problem{Path: keyPath + ".type", Message: "is required"})
+	}
+
+	schema, err := lookupSchema(section, typ)
+	if err != nil {
+		return append(problems, Problem{Path: keyPath + ".type", Message: err.Error()})
+	}
+	schema.Optional = append(append([]string{"type"}, schema.Optional...), common...)
+
+	return append(problems, checkKeys(keyPath, entry, schema)...)
+}
+
+// checkSchedule checks schedule is a cron expression and every a duration,
+// only one of them may be set
+func checkSchedule(keyPath string, model map[string]interface{}) (problems []Problem) {
+	problems = checkDuration(keyPath, model, "every")
+
+	schedule, isString := model["schedule"].(string)
+	if !isBlank(model["schedule"]) && !isReference(schedule) {
+		if _, err := cron.ParseStandard(schedule); !isString || err != nil {
+			problems = append(problems, Problem{Path: keyPath + ".schedule", Message: `must be a cron expression like "0 3 * * *"`})
+		}
+	}
+	if !isBlank(model["schedule"]) && !isBlank(model["every"]) {
+		problems = append(problems, Problem{Path: keyPath, Message: "schedule and every are both set, only one is allowed"})
+	}
+	return
+}
+
+// checkConcurrency checks concurrency is a whole number of 1 or more, when
+// present, keyPath is empty or ends with a dot
+func checkConcurrency(keyPath string, section map[string]interface{}) []Problem {
+	value, present := section["concurrency"]
+	if !present {
+		return nil
+	}
+	if s, ok := value.(string); ok && isReference(s) {
+		return nil
+	}
+	if n, ok := value.(int); !ok || n < 1 {
+		return []Problem{{Path: keyPath + "concurrency", Message: "must be a whole number of 1 or more"}}
+	}
+	return nil
+}
+
+// isSize tells whether value is a size above 0 like 64MB, 512kb or 1048576
+func isSize(value interface{}) bool {
+	switch v := value.(type) {
+	case int:
+		return v > 0
+	case string:
+		return isReference(v) || sizeRegexp.MatchString(v)
+	}
+	return false
+}
+
+// checkDuration checks the keys are durations like 30m, when present
+func checkDuration(keyPath string, section map[string]interface{}, keys ...string) (problems []Problem) {
+	for _, key := range keys {
+		value, ok := section[key].(string)
+		if isReference(value) {
+			continue
+		}
+		if !ok {
+			if _, present := section[key]; present {
+				problems = append(problems, Problem{Path: keyPath + "." + key, Message: "must be a duration like 30m"})
+			}
+			continue
+		}
+		if d, err := time.ParseDuration(value); err != nil || d < 0 {
+			problems = append(problems, Problem{Path: keyPath + "." + key, Message: "must be a duration like 30m"})
+		}
+	}
+	return
+}
+
+// checkCount checks the keys are whole numbers not below 0, when present
+func checkCount(keyPath string, section map[string]interface{}, keys ...string) (problems []Problem) {
+	for _, key := range keys {
+		value, present := section[key]
+		if !present {
+			continue
+		}
+		if s, ok := value.(string); ok && isReference(s) {
+			continue
+		}
+		if n, ok := value.(int); !ok || n < 0 {
+			problems = append(problems, Problem{Path: keyPath + "." + key, Message: "must be a whole number like 3"})
+		}
+	}
+	return
+}
+
+func checkKeys(keyPath string, section map[string]interface{}, schema Schema) (problems []Problem) {
+	for _, key := range schema.Required {
+		if isBlank(section[key]) {
+			problems = append(problems, Problem{Path: keyPath + "." + key, Message: "is required"})
+		}
+	}
+	for _, key := range sortedKeys(section) {
+		if !schema.has(key) {
+			problems = append(problems, Problem{Path: keyPath + "." + key, Message: "unknown key"})
+		}
+	}
+	return
+}
+
+// isReference tells whether value is a secret reference, only known once
+// resolved
+func isReference(value string) bool {
+	return strings.HasPrefix(value, "file:") || strings.HasPrefix(value, "cmd:") || envRefRegexp.MatchString(value)
+}
+
+func isBlank(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func init() {
+	RegisterSchema(SectionStorages, "checktest", Schema{Required: []string{"path"}, Optional: []string{"password"}})
+}
+
+func loadUnresolved(t *testing.T, yaml string) *Config {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "gobackup.yml")
+	if err := os.WriteFile(file, []byte(yaml), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadUnresolved(file)
+	if err != nil {
+		t.Fatalf("LoadUnresolved() error = %v", err)
+	}
+	return cfg
+}
+
+func checkPaths(cfg *Config) (paths []string) {
+	for _, problem := range cfg.Check() {
+		paths = append(paths, problem.Path+": "+problem.Message)
+	}
+	return
+}
+
+func TestCheckUnresolvedSecrets(t *testing.T) {
+	cfg := loadUnresolved(t, `
+models:
+  app:
+    bogus: 1
+    before_script: echo ${
+    store_with:
+      type: checktest
+      path: ${GOBACKUP_TEST_UNSET_PATH}
+      password: file:/run/secrets/gobackup-test-missing
+      keep: ${GOBACKUP_TEST_KEEP}
+      upload_timeout: cmd:echo 1m
+`)
+	want := []string{"models.app.bogus: unknown key"}
+	if got := checkPaths(cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("Check() = %q, want %q", got, want)
+	}
+}
+
+func TestCheckReferences(t *testing.T) {
+	settings := map[string]interface{}{
+		"a": "file:",
+		"b": "cmd: ",
+		"c": "${UNCLOSED",
+		"d": "$${LITERAL} and ${OK}",
+		"e": []interface{}{"ok", "x${1BAD}"},
+		"f": "file:/run/secrets/db",
+	}
+	var got []string
+	for _, problem := range checkReferences(settings, "") {
+		got = append(got, problem.Path)
+	}
+	want := []string{"a", "b", "c", "e[1]"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("checkReferences() = %q, want %q", got, want)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	cases := []struct {
+		name string
+		yaml string
+		want []string
+	}{
+		{
+			name: "valid",
+			yaml: `
+concurrency: 2
+models:
+  app:
+    schedule: "0 3 * * *"
+    concurrency: 3
+    stream_chunk_size: 16MB
+    temp_files: true
+    store_with: {type: checktest, path: /backup, keep: 3}
+`,
+		},
+		{
+			name: "no models",
+			yaml: `concurrency: 1`,
+			want: []string{"models: is required"},
+		},
+		{
+			name: "no storage",
+			yaml: `
+models:
+  app:
+    archive: {includes: [/etc]}
+`,
+			want: []string{"models.app: store_with or storages is required"},
+		},
+		{
+			name: "required keys",
+			yaml: `
+models:
+  app:
+    store_with: {keep: 3}
+    storages:
+      offsite: {type: checktest}
+    archive: {excludes: [/tmp]}
+`,
+			want: []string{
+				"models.app.store_with.type: is required",
+				"models.app.storages.offsite.path: is required",
+				"models.app.archive.includes: is required",
+			},
+		},
+		{
+			name: "unknown keys",
+			yaml: `
+models:
+  app:
+    bogus: 1
+    store_with: {type: checktest, path: /backup, bucket: x}
+`,
+			want: []string{
+				"models.app.bogus: unknown key",
+				"models.app.store_with.bucket: unknown key",
+			},
+		},
+		{
+			name: "unsupported types",
+			yaml: `
+models:
+  app:
+    store_with: {type: nosuchstorage}
+    compress_with: {type: nosuchcompressor}
+`,
+			want: []string{
+				"models.app.store_with.type: ",
+				"models.app.compress_with.type: ",
+			},
+		},
+		{
+			name: "model values",
+			yaml: `
+concurrency: 0
+models:
+  app:
+    schedule: not a cron
+    every: banana
+    concurrency: -3
+    stream_chunk_size: 64M
+    temp_files: sometimes
+    store_with: {type: checktest, path: /backup, keep: -1}
+`,
+			want: []string{
+				"concurrency: must be a whole number of 1 or more",
+				"models.app.store_with.keep: must be a whole number like 3",
+				"models.app.every: must be a duration like 30m",
+				`models.app.schedule: must be a cron expression like "0 3 * * *"`,
+				"models.app: schedule and every are both set, only one is allowed",
+				"models.app.concurrency: must be a whole number of 1 or more",
+				"models.app.stream_chunk_size: must be a size like 64MB",
+				"models.app.temp_files: must be true or false",
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := checkPaths(loadUnresolved(t, c.yaml))
+			if len(got) != len(c.want) {
+				t.Fatalf("Check() = %q, want %q", got, c.want)
+			}
+			for i := range got {
+				// the messages of unsupported types come from the registry
+				if !strings.HasPrefix(got[i], c.want[i]) {
+					t.Errorf("Check()[%d] = %q, want %q", i, got[i], c.want[i])
+				}
+			}
+		})
+	}
+}
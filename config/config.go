@@ -5,18 +5,17 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/holgerhuo/gobackup/helper"
 	"github.com/spf13/viper"
 )
 
 var (
-	// Exist Is config file exist
-	Exist bool
-	// Models configs
-	Models []ModelConfig
-	// HomeDir of user
-	HomeDir = os.Getenv("HOME")
+	// secretKeys config keys holding secrets, masked in logs
+	secretKeys = []string{"password", "secret_access_key", "token", "passphrase", "identity_passphrase"}
 )
 
 // ModelConfig for special case
@@ -28,11 +27,26 @@ type ModelConfig struct {
 	EncryptWith  SubConfig
 	StoreWith    SubConfig
 	Archive      *viper.Viper
-	Databases    []SubConfig
-	Storages     []SubConfig
-	Viper        *viper.Viper
-	BeforeScript   string
-	AfterScript    string
+	// ArchiveTimeout `timeout` of archive, 0 means no limit
+	ArchiveTimeout time.Duration
+	Databases      []SubConfig
+	Storages       []SubConfig
+	// StoragePolicy decides when a model with several storages fails:
+	// "all" (default) every storage must succeed, "any" one is enough
+	StoragePolicy string
+	Viper         *viper.Viper
+	BeforeScript  string
+	AfterScript   string
+	// Schedule cron expression for daemon mode, example: 0 3 * * *
+	Schedule string
+	// Every runs the model at a fixed interval in daemon mode, example: 6h
+	Every time.Duration
+	// TempFiles writes every stage to TempPath instead of streaming
+	TempFiles bool
+	// ChunkSize in bytes of the parts a streamed dump is split into
+	ChunkSize int
+	// Concurrency how many databases of the model dump at the same time
+	Concurrency int
 }
 
 // SubConfig sub config info
@@ -40,87 +54,230 @@ type SubConfig struct {
 	Name  string
 	Type  string
 	Viper *viper.Viper
+	// Timeout of the stage, `timeout` of databases, compress_with and
+	// encrypt_with, `upload_timeout` of storages. 0 means no limit
+	Timeout time.Duration
+	// Retry of a failed dump or upload, `retries`, `backoff` and
+	// `max_backoff` of databases and storages
+	Retry helper.RetryPolicy
+}
+
+// Config of a loaded config file, independent of other loaded configs
+type Config struct {
+	// File the config was read from
+	File   string
+	Models []ModelConfig
+	// Concurrency how many models perform at the same time, default 1
+	Concurrency int
+	viper       *viper.Viper
+	// unresolved secret references are left as written, see LoadUnresolved
+	unresolved bool
 }
 
-// loadConfig from:
-// - ./gobackup.yml
+// Load a config from configFile, or when empty from:
 // - ~/.gobackup/gobackup.yml
 // - /etc/gobackup/gobackup.yml
-func Init(configFile string) {
-	viper.SetConfigType("yaml")
+func Load(configFile string) (*Config, error) {
+	v, err := readConfig(configFile)
+	if err != nil {
+		return nil, err
+	}
+	if err = resolveSecrets(v); err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{
+		File:  v.ConfigFileUsed(),
+		viper: v,
+	}
+	v.SetDefault("concurrency", 1)
+	cfg.Concurrency = v.GetInt("concurrency")
+	if cfg.Concurrency < 1 {
+		return nil, fmt.Errorf("concurrency must be 1 or more")
+	}
+
+	for key := range v.GetStringMap("models") {
+		model, err := loadModel(v, key)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Models = append(cfg.Models, model)
+	}
+	if len(cfg.Models) == 0 {
+		return nil, fmt.Errorf("no models found in %s", cfg.File)
+	}
+	sort.Slice(cfg.Models, func(i, j int) bool {
+		return cfg.Models[i].Name < cfg.Models[j].Name
+	})
+
+	return cfg, nil
+}
+
+// LoadUnresolved reads configFile like Load for Check only, secret
+// references are left as written: files aren't read and commands aren't
+// run, so a config using secrets of another host can be checked.
+func LoadUnresolved(configFile string) (*Config, error) {
+	v, err := readConfig(configFile)
+	if err != nil {
+		return nil, err
+	}
+	return &Config{
+		File:       v.ConfigFileUsed(),
+		viper:      v,
+		unresolved: true,
+	}, nil
+}
+
+func readConfig(configFile string) (*viper.Viper, error) {
+	v := viper.New()
+	v.SetConfigType("yaml")
 
 	// set config file directly
 	if len(configFile) > 0 {
-		viper.SetConfigFile(configFile)
+		v.SetConfigFile(configFile)
 	} else {
-		viper.SetConfigName("gobackup")
+		v.SetConfigName("gobackup")
 
 		// ~/.gobackup/gobackup.yml
-		viper.AddConfigPath("$HOME/.gobackup")
+		v.AddConfigPath("$HOME/.gobackup")
 		// /etc/gobackup/gobackup.yml
-		viper.AddConfigPath("/etc/gobackup/")
+		v.AddConfigPath("/etc/gobackup/")
 	}
 
-	err := viper.ReadInConfig()
+	err := v.ReadInConfig()
 	if err != nil {
-		slog.Error("Configuration loading failed", 
+		slog.Error("Configuration loading failed",
 			"component", "config",
 			"configFile", configFile,
 			"error", err)
-		return
+		return nil, fmt.Errorf("load config failed: %s", err)
 	}
-	
-	slog.Debug("Configuration loaded successfully", 
+
+	slog.Debug("Configuration loaded successfully",
 		"component", "config",
-		"configFile", viper.ConfigFileUsed())
+		"configFile", v.ConfigFileUsed())
+	return v, nil
+}
 
-	Exist = true
-	Models = []ModelConfig{}
-	for key := range viper.GetStringMap("models") {
-		Models = append(Models, loadModel(key))
+// NewModel builds a model from settings with the keys of a model in
+// gobackup.yml, for use without a config file.
+func NewModel(name string, settings map[string]interface{}) (model ModelConfig, err error) {
+	v := viper.New()
+	err = v.MergeConfigMap(map[string]interface{}{
+		"models": map[string]interface{}{name: settings},
+	})
+	if err != nil {
+		return
+	}
+	if err = resolveSecrets(v); err != nil {
+		return
 	}
+
+	return loadModel(v, name)
+}
+
+// NewWorkDir gives the model a new temp directory, every run of a model
+// works in a directory of its own.
+func (model *ModelConfig) NewWorkDir() {
+	model.TempPath = filepath.Join(os.TempDir(), "gobackup", fmt.Sprintf("%d-%s", time.Now().UnixNano(), model.Name))
+	model.DumpPath = filepath.Join(model.TempPath, model.Name)
 }
 
-func loadModel(key string) (model ModelConfig) {
+func loadModel(v *viper.Viper, key string) (model ModelConfig, err error) {
 	model.Name = key
-	model.TempPath = filepath.Join(os.TempDir(), "gobackup", fmt.Sprintf("%d", time.Now().UnixNano()))
-	model.DumpPath = filepath.Join(model.TempPath, key)
-	model.Viper = viper.Sub("models." + key)
+	model.NewWorkDir()
+	model.Viper = v.Sub("models." + key)
 
 	model.CompressWith = SubConfig{
-		Type:  model.Viper.GetString("compress_with.type"),
-		Viper: model.Viper.Sub("compress_with"),
+		Type:    model.Viper.GetString("compress_with.type"),
+		Viper:   model.Viper.Sub("compress_with"),
+		Timeout: model.Viper.GetDuration("compress_with.timeout"),
 	}
 
 	model.EncryptWith = SubConfig{
-		Type:  model.Viper.GetString("encrypt_with.type"),
-		Viper: model.Viper.Sub("encrypt_with"),
+		Type:    model.Viper.GetString("encrypt_with.type"),
+		Viper:   model.Viper.Sub("encrypt_with"),
+		Timeout: model.Viper.GetDuration("encrypt_with.timeout"),
 	}
 
 	model.StoreWith = SubConfig{
-		Type:  model.Viper.GetString("store_with.type"),
-		Viper: model.Viper.Sub("store_with"),
+		Name:    "store_with",
+		Type:    model.Viper.GetString("store_with.type"),
+		Viper:   model.Viper.Sub("store_with"),
+		Timeout: model.Viper.GetDuration("store_with.upload_timeout"),
+		Retry:   loadRetry(model.Viper.Sub("store_with")),
 	}
 
 	model.Archive = model.Viper.Sub("archive")
+	model.ArchiveTimeout = model.Viper.GetDuration("archive.timeout")
 
 	model.BeforeScript = model.Viper.GetString("before_script")
 	model.AfterScript = model.Viper.GetString("after_script")
 
+	model.Schedule = model.Viper.GetString("schedule")
+	// a bad every would leave the model unscheduled without a word
+	if every := model.Viper.GetString("every"); len(every) > 0 {
+		if model.Every, err = time.ParseDuration(every); err != nil || model.Every < 0 {
+			return model, fmt.Errorf("model: %s every %q must be a duration like 6h", key, every)
+		}
+	}
+
+	model.TempFiles = model.Viper.GetBool("temp_files")
+	model.Viper.SetDefault("stream_chunk_size", "64MB")
+	model.ChunkSize = int(model.Viper.GetSizeInBytes("stream_chunk_size"))
+	if model.ChunkSize <= 0 {
+		model.ChunkSize = 64 << 20
+	}
+
+	model.Viper.SetDefault("concurrency", 1)
+	model.Concurrency = model.Viper.GetInt("concurrency")
+	if model.Concurrency < 1 {
+		return model, fmt.Errorf("model: %s concurrency must be 1 or more", key)
+	}
+
+	model.Viper.SetDefault("storage_policy", "all")
+	model.StoragePolicy = model.Viper.GetString("storage_policy")
+
 	loadDatabasesConfig(&model)
 	loadStoragesConfig(&model)
+	registerSecrets(model.Viper)
 
 	return
 }
 
+// registerSecrets masks the values of secretKeys in logged commands
+func registerSecrets(v *viper.Viper) {
+	for _, key := range v.AllKeys() {
+		for _, secretKey := range secretKeys {
+			if key == secretKey || strings.HasSuffix(key, "."+secretKey) {
+				helper.RegisterSecret(v.GetString(key))
+			}
+		}
+	}
+}
+
+// loadRetry reads the retry policy of a database or storage, v may be nil
+func loadRetry(v *viper.Viper) (retry helper.RetryPolicy) {
+	if v == nil {
+		return
+	}
+	return helper.RetryPolicy{
+		Retries:    v.GetInt("retries"),
+		Backoff:    v.GetDuration("backoff"),
+		MaxBackoff: v.GetDuration("max_backoff"),
+	}
+}
+
 func loadDatabasesConfig(model *ModelConfig) {
 	subViper := model.Viper.Sub("databases")
 	for key := range model.Viper.GetStringMap("databases") {
 		dbViper := subViper.Sub(key)
 		model.Databases = append(model.Databases, SubConfig{
-			Name:  key,
-			Type:  dbViper.GetString("type"),
-			Viper: dbViper,
+			Name:    key,
+			Type:    dbViper.GetString("type"),
+			Viper:   dbViper,
+			Timeout: dbViper.GetDuration("timeout"),
+			Retry:   loadRetry(dbViper),
 		})
 	}
 }
@@ -130,16 +287,21 @@ func loadStoragesConfig(model *ModelConfig) {
 	for key := range model.Viper.GetStringMap("storages") {
 		dbViper := subViper.Sub(key)
 		model.Storages = append(model.Storages, SubConfig{
-			Name:  key,
-			Type:  dbViper.GetString("type"),
-			Viper: dbViper,
+			Name:    key,
+			Type:    dbViper.GetString("type"),
+			Viper:   dbViper,
+			Timeout: dbViper.GetDuration("upload_timeout"),
+			Retry:   loadRetry(dbViper),
 		})
 	}
+	sort.Slice(model.Storages, func(i, j int) bool {
+		return model.Storages[i].Name < model.Storages[j].Name
+	})
 }
 
 // GetModelByName get model by name
-func GetModelByName(name string) (model *ModelConfig) {
-	for _, m := range Models {
+func (cfg *Config) GetModelByName(name string) (model *ModelConfig) {
+	for _, m := range cfg.Models {
 		if m.Name == name {
 			model = &m
 			return
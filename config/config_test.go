@@ -0,0 +1,38 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewModelEvery(t *testing.T) {
+	cases := []struct {
+		every   interface{}
+		want    time.Duration
+		wantErr bool
+	}{
+		{every: nil, want: 0},
+		{every: "6h", want: 6 * time.Hour},
+		{every: "90m", want: 90 * time.Minute},
+		{every: "6hours", wantErr: true},
+		{every: "banana", wantErr: true},
+		{every: "-1h", wantErr: true},
+		{every: 3600, wantErr: true},
+	}
+	for _, c := range cases {
+		settings := map[string]interface{}{
+			"store_with": map[string]interface{}{"type": "local", "path": "/backup"},
+		}
+		if c.every != nil {
+			settings["every"] = c.every
+		}
+		model, err := NewModel("app", settings)
+		if (err != nil) != c.wantErr {
+			t.Errorf("NewModel(every: %v) error = %v, wantErr %v", c.every, err, c.wantErr)
+			continue
+		}
+		if err == nil && model.Every != c.want {
+			t.Errorf("NewModel(every: %v) Every = %s, want %s", c.every, model.Every, c.want)
+		}
+	}
+}
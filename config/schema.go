@@ -0,0 +1,121 @@
+package config
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/holgerhuo/gobackup/plugin"
+)
+
+// Schema known keys of a config section
+type Schema struct {
+	// Required keys must have a value
+	Required []string
+	// Optional keys may be left out
+	Optional []string
+}
+
+func (s Schema) has(key string) bool {
+	for _, k := range s.Required {
+		if k == key {
+			return true
+		}
+	}
+	for _, k := range s.Optional {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	modelSchema = Schema{
+		Optional: []string{
+			"compress_with", "encrypt_with", "store_with", "storages", "storage_policy",
+			"databases", "archive", "before_script", "after_script", "schedule", "every",
+			"temp_files", "stream_chunk_size", "concurrency",
+		},
+	}
+
+	archiveSchema = Schema{
+		Required: []string{"includes"},
+		Optional: []string{"excludes", "timeout"},
+	}
+
+	// schemas of the types by section, every type registers its schema
+	databaseSchemas   = map[string]Schema{}
+	storageSchemas    = map[string]Schema{}
+	compressorSchemas = map[string]Schema{}
+	encryptorSchemas  = map[string]Schema{}
+)
+
+// Sections of the config types are registered for
+const (
+	SectionDatabases  = "databases"
+	SectionStorages   = "storages"
+	SectionCompressor = "compress_with"
+	SectionEncryptor  = "encrypt_with"
+)
+
+// schemasMu guards the schema tables against RegisterSchema
+var schemasMu sync.RWMutex
+
+func sectionSchemas(section string) map[string]Schema {
+	switch section {
+	case SectionDatabases:
+		return databaseSchemas
+	case SectionStorages:
+		return storageSchemas
+	case SectionCompressor:
+		return compressorSchemas
+	case SectionEncryptor:
+		return encryptorSchemas
+	}
+	return nil
+}
+
+// RegisterSchema makes Check accept a type, the built in types register
+// theirs from init. It panics when the section is unknown or the type is
+// taken.
+func RegisterSchema(section, typ string, schema Schema) {
+	schemasMu.Lock()
+	defer schemasMu.Unlock()
+
+	schemas := sectionSchemas(section)
+	if schemas == nil {
+		panic(fmt.Sprintf("config: unknown section %s", section))
+	}
+	if _, ok := schemas[typ]; ok {
+		panic(fmt.Sprintf("config: %s type %s is already registered", section, typ))
+	}
+	schemas[typ] = schema
+}
+
+// pluginKinds of the sections
+var pluginKinds = map[string]string{
+	SectionDatabases:  plugin.KindDatabase,
+	SectionStorages:   plugin.KindStorage,
+	SectionCompressor: plugin.KindCompressor,
+	SectionEncryptor:  plugin.KindEncryptor,
+}
+
+// lookupSchema of a registered type, or asks the plugin of the type
+func lookupSchema(section, typ string) (Schema, error) {
+	schemasMu.RLock()
+	schema, ok := sectionSchemas(section)[typ]
+	schemasMu.RUnlock()
+	if ok {
+		return schema, nil
+	}
+
+	p, ok := plugin.Lookup(pluginKinds[section], typ)
+	if !ok {
+		return schema, fmt.Errorf("unsupported type %q", typ)
+	}
+	desc, err := p.Describe()
+	if err != nil {
+		return schema, err
+	}
+	return Schema{Required: desc.Required, Optional: desc.Optional}, nil
+}
@@ -0,0 +1,190 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/holgerhuo/gobackup/helper"
+	"github.com/spf13/viper"
+)
+
+var (
+	// envRefRegexp matches ${VAR}, and $${VAR} which stays a literal ${VAR}
+	envRefRegexp = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+	// unresolvedKeys are run by sh -c, ${VAR} in them is left to the shell
+	unresolvedKeys = []string{"before_script", "after_script"}
+
+	// secretCommandTimeout stops a hung cmd: reference from blocking the load
+	secretCommandTimeout = 30 * time.Second
+)
+
+// resolveSecrets replaces references in every config value:
+//
+//	${ENV_VAR}          environment variable, also inside a longer value
+//	$${ENV_VAR}         a literal ${ENV_VAR}
+//	file:/run/secrets/x content of the file
+//	cmd:pass show db    stdout of the command, run by sh
+//
+// A value made of a single reference is registered as a secret, so it is
+// masked in logs whatever key holds it. before_script and after_script are
+// left alone, they run with sh -c which expands them.
+func resolveSecrets(v *viper.Viper) error {
+	settings := v.AllSettings()
+	if err := resolveMap(settings, ""); err != nil {
+		return err
+	}
+	return v.MergeConfigMap(settings)
+}
+
+func resolveMap(m map[string]interface{}, prefix string) error {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if slices.Contains(unresolvedKeys, key) {
+			continue
+		}
+		value, err := resolveValue(m[key], prefix+key)
+		if err != nil {
+			return err
+		}
+		m[key] = value
+	}
+	return nil
+}
+
+func resolveValue(value interface{}, path string) (interface{}, error) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		return value, resolveMap(value, path+".")
+	case []interface{}:
+		for i, item := range value {
+			resolved, err := resolveValue(item, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			value[i] = resolved
+		}
+		return value, nil
+	case string:
+		resolved, err := resolveString(value)
+		if err != nil {
+			return nil, fmt.Errorf("resolve %s failed: %s", path, err)
+		}
+		return resolved, nil
+	}
+	return value, nil
+}
+
+func resolveString(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, "file:"):
+		data, err := os.ReadFile(helper.ExplandHome(strings.TrimPrefix(value, "file:")))
+		if err != nil {
+			return "", err
+		}
+		return registerResolved(strings.TrimRight(string(data), "\r\n")), nil
+	case strings.HasPrefix(value, "cmd:"):
+		output, err := runSecretCommand(strings.TrimPrefix(value, "cmd:"))
+		if err != nil {
+			return "", err
+		}
+		return registerResolved(strings.TrimRight(output, "\r\n")), nil
+	}
+
+	var missing []string
+	resolved := envRefRegexp.ReplaceAllStringFunc(value, func(ref string) string {
+		if strings.HasPrefix(ref, "$$") {
+			return ref[1:]
+		}
+		name := envRefRegexp.FindStringSubmatch(ref)[1]
+		env, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return env
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+	if envRefRegexp.FindString(value) == value && !strings.HasPrefix(value, "$$") {
+		registerResolved(resolved)
+	}
+	return resolved, nil
+}
+
+// checkReferences reports malformed references in the values of m without
+// resolving them, for configs read by LoadUnresolved
+func checkReferences(m map[string]interface{}, prefix string) (problems []Problem) {
+	for _, key := range sortedKeys(m) {
+		if slices.Contains(unresolvedKeys, key) {
+			continue
+		}
+		problems = append(problems, checkReferenceValue(m[key], prefix+key)...)
+	}
+	return
+}
+
+func checkReferenceValue(value interface{}, path string) []Problem {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		return checkReferences(value, path+".")
+	case []interface{}:
+		var problems []Problem
+		for i, item := range value {
+			problems = append(problems, checkReferenceValue(item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return problems
+	case string:
+		switch {
+		case value == "file:":
+			return []Problem{{Path: path, Message: "file: reference needs a path"}}
+		case value == "cmd:" || (strings.HasPrefix(value, "cmd:") && len(strings.TrimSpace(value[4:])) == 0):
+			return []Problem{{Path: path, Message: "cmd: reference needs a command"}}
+		case strings.HasPrefix(value, "file:"), strings.HasPrefix(value, "cmd:"):
+			return nil
+		}
+		if strings.Contains(envRefRegexp.ReplaceAllString(value, ""), "${") {
+			return []Problem{{Path: path, Message: "malformed ${VAR} reference, write $${ for a literal ${"}}
+		}
+	}
+	return nil
+}
+
+// runSecretCommand returns the stdout of command, killed after
+// secretCommandTimeout
+func runSecretCommand(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), secretCommandTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// children of sh may keep the pipes open after it was killed
+	cmd.WaitDelay = time.Second
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("command timed out after %s", secretCommandTimeout)
+		}
+		return "", fmt.Errorf("%s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+func registerResolved(value string) string {
+	helper.RegisterSecret(value)
+	return value
+}
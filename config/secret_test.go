@@ -0,0 +1,103 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestResolveString(t *testing.T) {
+	t.Setenv("GOBACKUP_TEST_USER", "root")
+	t.Setenv("GOBACKUP_TEST_EMPTY", "")
+	os.Unsetenv("GOBACKUP_TEST_MISSING")
+
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	os.WriteFile(secretFile, []byte("s3cret\n"), 0600)
+
+	cases := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr string
+	}{
+		{name: "plain", value: "localhost", want: "localhost"},
+		{name: "env", value: "${GOBACKUP_TEST_USER}", want: "root"},
+		{name: "env inside", value: "/home/${GOBACKUP_TEST_USER}/backup", want: "/home/root/backup"},
+		{name: "empty env", value: "${GOBACKUP_TEST_EMPTY}", want: ""},
+		{name: "missing env", value: "${GOBACKUP_TEST_MISSING}", wantErr: "GOBACKUP_TEST_MISSING is not set"},
+		{name: "escaped", value: "$${GOBACKUP_TEST_MISSING}", want: "${GOBACKUP_TEST_MISSING}"},
+		{name: "escaped inside", value: "a $${X} b ${GOBACKUP_TEST_USER}", want: "a ${X} b root"},
+		{name: "not a reference", value: "$GOBACKUP_TEST_USER", want: "$GOBACKUP_TEST_USER"},
+		{name: "file", value: "file:" + secretFile, want: "s3cret"},
+		{name: "missing file", value: "file:" + secretFile + ".none", wantErr: "no such file"},
+		{name: "cmd", value: "cmd:printf 'hunter2\\n'", want: "hunter2"},
+		{name: "failing cmd", value: "cmd:echo nope >&2; exit 3", wantErr: "nope"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := resolveString(c.value)
+			if len(c.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("resolveString(%q) error = %v, want %q", c.value, err, c.wantErr)
+				}
+				return
+			}
+			if err != nil || got != c.want {
+				t.Fatalf("resolveString(%q) = %q, %v, want %q", c.value, got, err, c.want)
+			}
+		})
+	}
+}
+
+func TestResolveStringCommandTimeout(t *testing.T) {
+	timeout := secretCommandTimeout
+	secretCommandTimeout = 100 * time.Millisecond
+	defer func() { secretCommandTimeout = timeout }()
+
+	start := time.Now()
+	_, err := resolveString("cmd:sleep 10")
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("resolveString() error = %v, want timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("resolveString() took %s", elapsed)
+	}
+}
+
+func TestResolveSecretsSkipsScripts(t *testing.T) {
+	os.Unsetenv("GOBACKUP_TEST_MISSING")
+	t.Setenv("GOBACKUP_TEST_HOST", "db.local")
+
+	v := viper.New()
+	v.SetConfigType("yaml")
+	err := v.ReadConfig(strings.NewReader(`
+models:
+  app:
+    before_script: echo ${GOBACKUP_TEST_MISSING}
+    after_script: rm -rf "${TMPDIR}/x"
+    databases:
+      app:
+        host: ${GOBACKUP_TEST_HOST}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = resolveSecrets(v); err != nil {
+		t.Fatalf("resolveSecrets() error = %v", err)
+	}
+	if got := v.GetString("models.app.before_script"); got != "echo ${GOBACKUP_TEST_MISSING}" {
+		t.Errorf("before_script = %q", got)
+	}
+	if got := v.GetString("models.app.after_script"); got != `rm -rf "${TMPDIR}/x"` {
+		t.Errorf("after_script = %q", got)
+	}
+	if got := v.GetString("models.app.databases.app.host"); got != "db.local" {
+		t.Errorf("host = %q, want db.local", got)
+	}
+}
@@ -1,12 +1,19 @@
 package database
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log/slog"
+	"os"
 	"path"
+	"path/filepath"
 
 	"github.com/holgerhuo/gobackup/config"
 	"github.com/holgerhuo/gobackup/helper"
+	"github.com/holgerhuo/gobackup/plugin"
 	"github.com/spf13/viper"
 )
 
@@ -21,7 +28,22 @@ type Base struct {
 
 // Context database interface
 type Context interface {
-	perform() error
+	perform(runCtx context.Context) error
+	restore(runCtx context.Context, opts RestoreOptions) error
+	// stream writes the dump through newEntry instead of dumpPath
+	stream(runCtx context.Context, newEntry EntryFunc) error
+}
+
+// EntryFunc opens a writer for a dump file, name is relative to the dump
+// path of the model, example: mysql/app/app.sql
+type EntryFunc func(name string) (io.WriteCloser, error)
+
+// RestoreOptions where restored databases go
+type RestoreOptions struct {
+	// Host overrides the host config of mysql and postgresql databases
+	Host string
+	// TargetDir receives redis RDB files, default to the directory of rdb_path
+	TargetDir string
 }
 
 func newBase(model config.ModelConfig, dbConfig config.SubConfig) (base Base) {
@@ -32,74 +54,226 @@ func newBase(model config.ModelConfig, dbConfig config.SubConfig) (base Base) {
 		name:     dbConfig.Name,
 	}
 	base.dumpPath = path.Join(model.DumpPath, dbConfig.Type, base.name)
-	helper.MkdirP(base.dumpPath)
 	return
 }
 
-// New - initialize Database
-func runModel(model config.ModelConfig, dbConfig config.SubConfig) (err error) {
+func newContext(model config.ModelConfig, dbConfig config.SubConfig) (Context, error) {
 	base := newBase(model, dbConfig)
-	var ctx Context
-	switch dbConfig.Type {
-	case "mysql":
-		ctx = &MySQL{Base: base}
-	case "redis":
-		ctx = &Redis{Base: base}
-	case "postgresql":
-		ctx = &PostgreSQL{Base: base}
-	default:
-		err = fmt.Errorf("model: %s databases.%s config `type: %s`, but is not implement", model.Name, dbConfig.Name, dbConfig.Type)
-		slog.Warn("Unsupported database type", 
-			"component", "database",
-			"model", model.Name,
-			"database", dbConfig.Name,
-			"type", dbConfig.Type,
-			"error", err)
+
+	registryMu.RLock()
+	fn, ok := registry[dbConfig.Type]
+	registryMu.RUnlock()
+	if ok {
+		return fn(base)
+	}
+	if p, ok := plugin.Lookup(plugin.KindDatabase, dbConfig.Type); ok {
+		return newExternal(base, pluginFactory(p))
+	}
+	return nil, fmt.Errorf("model: %s databases.%s config `type: %s`, but is not implement", model.Name, dbConfig.Name, dbConfig.Type)
+}
+
+// Validate check every database of model has a supported type
+func Validate(model config.ModelConfig) error {
+	var errs []error
+	for _, dbCfg := range model.Databases {
+		if _, err := newContext(model, dbCfg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// New - initialize Database
+func runModel(runCtx context.Context, model config.ModelConfig, dbConfig config.SubConfig) (err error) {
+	ctx, err := newContext(model, dbConfig)
+	if err != nil {
 		return
 	}
+	dumpPath := path.Join(model.DumpPath, dbConfig.Type, dbConfig.Name)
+	helper.MkdirP(dumpPath)
 
-	slog.Info("Database operation starting", 
+	slog.Info("Database operation starting",
 		"component", "database",
-		slog.Group("database", 
+		slog.Group("database",
 			"type", dbConfig.Type,
-			"name", base.name,
+			"name", dbConfig.Name,
 		),
 		"model", model.Name)
 
-	// perform
-	err = ctx.perform()
+	// perform, every attempt starts from an empty dump path and has its own
+	// timeout
+	stage := "database " + dbConfig.Name
+	err = helper.Retry(runCtx, dbConfig.Retry, stage, func(attempt int) error {
+		if attempt > 1 {
+			os.RemoveAll(dumpPath)
+			helper.MkdirP(dumpPath)
+		}
+		attemptCtx, cancel := helper.WithTimeout(runCtx, stage, dbConfig.Timeout)
+		defer cancel()
+		return ctx.perform(attemptCtx)
+	})
 	if err != nil {
 		return err
 	}
 	// Log successful completion
-	slog.Debug("Database operation completed", 
+	slog.Debug("Database operation completed",
 		"component", "database",
 		"type", dbConfig.Type,
-		"name", base.name,
+		"name", dbConfig.Name,
 		"model", model.Name)
 
 	return
 }
 
-// Run databases
-func Run(model config.ModelConfig) error {
+// Run databases, a database is stopped when runCtx is done, its timeout
+// expired or another database failed
+func Run(runCtx context.Context, model config.ModelConfig) error {
+	if len(model.Databases) == 0 {
+		return nil
+	}
+
+	slog.Info("Starting database backups",
+		"component", "database",
+		"model", model.Name,
+		"count", len(model.Databases),
+		"concurrency", model.Concurrency)
+	errs := helper.ParallelCancel(runCtx, len(model.Databases), model.Concurrency, func(runCtx context.Context, i int) error {
+		return runModel(runCtx, model, model.Databases[i])
+	})
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	slog.Info("Database backups completed",
+		"component", "database",
+		"model", model.Name)
+
+	return nil
+}
+
+// RunStream dumps every database of model through newEntry, no file is
+// written to the dump path unless the database has retries. The running
+// dumps are stopped once one failed.
+func RunStream(runCtx context.Context, model config.ModelConfig, newEntry EntryFunc) error {
+	if len(model.Databases) == 0 {
+		return nil
+	}
+
+	slog.Info("Starting database backups",
+		"component", "database",
+		"model", model.Name,
+		"count", len(model.Databases),
+		"concurrency", model.Concurrency,
+		"stream", true)
+	errs := helper.ParallelCancel(runCtx, len(model.Databases), model.Concurrency, func(runCtx context.Context, i int) error {
+		dbCfg := model.Databases[i]
+		ctx, err := newContext(model, dbCfg)
+		if err != nil {
+			return err
+		}
+		// the stream can't be written twice, a database with retries dumps
+		// to the dump path first
+		if dbCfg.Retry.Retries > 0 && !helper.IsDryRun(runCtx) {
+			return spoolDump(runCtx, model, dbCfg, newEntry)
+		}
+
+		slog.Info("Database operation starting",
+			"component", "database",
+			slog.Group("database",
+				"type", dbCfg.Type,
+				"name", dbCfg.Name,
+			),
+			"model", model.Name)
+		runCtx, cancel := helper.WithTimeout(runCtx, "database "+dbCfg.Name, dbCfg.Timeout)
+		defer cancel()
+		return ctx.stream(runCtx, newEntry)
+	})
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	slog.Info("Database backups completed",
+		"component", "database",
+		"model", model.Name)
+
+	return nil
+}
+
+// streamEntry runs fn with the writer of entry name, the entry is always closed
+func streamEntry(newEntry EntryFunc, name string, fn func(w io.Writer) error) error {
+	w, err := newEntry(name)
+	if err != nil {
+		return err
+	}
+	if err = fn(w); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// spoolDump dumps dbConfig to the dump path with its retries, then writes
+// the dump files through newEntry and removes them
+func spoolDump(runCtx context.Context, model config.ModelConfig, dbConfig config.SubConfig, newEntry EntryFunc) error {
+	if err := runModel(runCtx, model, dbConfig); err != nil {
+		return err
+	}
+
+	dumpPath := path.Join(model.DumpPath, dbConfig.Type, dbConfig.Name)
+	defer os.RemoveAll(dumpPath)
+	return filepath.WalkDir(dumpPath, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		name, err := filepath.Rel(model.DumpPath, filePath)
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		return streamEntry(newEntry, filepath.ToSlash(name), func(w io.Writer) error {
+			_, err := io.Copy(w, helper.NewContextReader(runCtx, file))
+			return err
+		})
+	})
+}
+
+// entryName of a dump file in the dump path of model
+func (ctx *Base) entryName(fileName string) string {
+	return path.Join(ctx.dbConfig.Type, ctx.name, fileName)
+}
+
+// Restore databases of model from the dumps extracted into dumpPath
+func Restore(runCtx context.Context, model config.ModelConfig, dumpPath string, opts RestoreOptions) error {
 	if len(model.Databases) == 0 {
 		return nil
 	}
 
-	slog.Info("Starting database backups", 
+	slog.Info("Starting database restores",
 		"component", "database",
 		"model", model.Name,
 		"count", len(model.Databases))
+
+	model.DumpPath = dumpPath
 	for _, dbCfg := range model.Databases {
-		err := runModel(model, dbCfg)
+		ctx, err := newContext(model, dbCfg)
 		if err != nil {
 			return err
 		}
+
+		slog.Info("Database restore starting",
+			"component", "database",
+			"type", dbCfg.Type,
+			"name", dbCfg.Name,
+			"model", model.Name)
+		if err = ctx.restore(runCtx, opts); err != nil {
+			return fmt.Errorf("restore %s failed: %s", dbCfg.Name, err)
+		}
 	}
-	slog.Info("Database backups completed", 
+
+	slog.Info("Database restores completed",
 		"component", "database",
 		"model", model.Name)
-
 	return nil
 }
@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/holgerhuo/gobackup/config"
+	"github.com/holgerhuo/gobackup/helper"
+)
+
+// Database is a database type added with Register, ctx is done when the
+// run is cancelled or the timeout of the database expired
+type Database interface {
+	// Dump writes the dump files of the database, every file through a
+	// writer of newEntry, names are relative like app.sql
+	Dump(ctx context.Context, newEntry EntryFunc) error
+	// Restore loads the dump files found in dir
+	Restore(ctx context.Context, dir string, opts RestoreOptions) error
+}
+
+// Factory creates the Database of an entry of `databases` in model,
+// settings are the keys of the entry like host or password.
+type Factory func(model, name string, settings map[string]interface{}) (Database, error)
+
+// newContextFunc creates the Context of a database type
+type newContextFunc func(base Base) (Context, error)
+
+var (
+	registryMu sync.RWMutex
+	// registry of database types, the built in types register from init
+	registry = map[string]newContextFunc{}
+)
+
+// register adds a database type with its config schema
+func register(typ string, fn newContextFunc, schema config.Schema) {
+	config.RegisterSchema(config.SectionDatabases, typ, schema)
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[typ] = fn
+}
+
+// Register adds a database type, usable as `type: typ` in the config.
+// It panics when typ is taken, schema lists the keys the type accepts.
+func Register(typ string, factory Factory, schema config.Schema) {
+	if factory == nil {
+		panic("database: Register factory is nil")
+	}
+	register(typ, func(base Base) (Context, error) {
+		return newExternal(base, factory)
+	}, schema)
+}
+
+// external runs a Database added with Register or found as plugin
+type external struct {
+	Base
+	db Database
+}
+
+func newExternal(base Base, factory Factory) (Context, error) {
+	var settings map[string]interface{}
+	if base.viper != nil {
+		settings = base.viper.AllSettings()
+	}
+	db, err := factory(base.model.Name, base.name, settings)
+	if err != nil {
+		return nil, fmt.Errorf("model: %s databases.%s: %s", base.model.Name, base.name, err)
+	}
+	return &external{Base: base, db: db}, nil
+}
+
+func (ctx *external) perform(runCtx context.Context) error {
+	return ctx.dump(runCtx, func(name string) (io.WriteCloser, error) {
+		filePath := filepath.Join(ctx.dumpPath, name)
+		helper.MkdirP(filepath.Dir(filePath))
+		return os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	})
+}
+
+func (ctx *external) stream(runCtx context.Context, newEntry EntryFunc) error {
+	return ctx.dump(runCtx, func(name string) (io.WriteCloser, error) {
+		return newEntry(ctx.entryName(name))
+	})
+}
+
+func (ctx *external) dump(runCtx context.Context, newEntry EntryFunc) error {
+	if helper.IsDryRun(runCtx) {
+		slog.Info("Dry run, database dump skipped",
+			"component", "database",
+			"model", ctx.model.Name,
+			"name", ctx.name,
+			"type", ctx.dbConfig.Type)
+		return nil
+	}
+
+	return ctx.db.Dump(runCtx, func(name string) (io.WriteCloser, error) {
+		cleaned := path.Clean(name)
+		if path.IsAbs(cleaned) || cleaned == "." || strings.HasPrefix(cleaned, "../") || cleaned == ".." {
+			return nil, fmt.Errorf("invalid dump file name %q", name)
+		}
+		return newEntry(cleaned)
+	})
+}
+
+func (ctx *external) restore(runCtx context.Context, opts RestoreOptions) error {
+	return ctx.db.Restore(runCtx, ctx.dumpPath, opts)
+}
@@ -1,11 +1,14 @@
 package database
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"path/filepath"
 	"strings"
 
+	"github.com/holgerhuo/gobackup/config"
 	"github.com/holgerhuo/gobackup/helper"
 )
 
@@ -28,7 +31,16 @@ type MySQL struct {
 	additionalOptions []string
 }
 
-func (ctx *MySQL) perform() (err error) {
+func init() {
+	register("mysql", func(base Base) (Context, error) {
+		return &MySQL{Base: base}, nil
+	}, config.Schema{
+		Required: []string{"database"},
+		Optional: []string{"host", "port", "username", "password", "additional_options"},
+	})
+}
+
+func (ctx *MySQL) load() error {
 	viper := ctx.viper
 	viper.SetDefault("host", "127.0.0.1")
 	viper.SetDefault("username", "root")
@@ -48,12 +60,19 @@ func (ctx *MySQL) perform() (err error) {
 	if len(ctx.database) == 0 {
 		return fmt.Errorf("mysql database config is required")
 	}
+	return nil
+}
 
-	err = ctx.dump()
+func (ctx *MySQL) perform(runCtx context.Context) (err error) {
+	if err = ctx.load(); err != nil {
+		return
+	}
+
+	err = ctx.dump(runCtx)
 	return
 }
 
-func (ctx *MySQL) dumpArgs() []string {
+func (ctx *MySQL) connArgs() []string {
 	dumpArgs := []string{}
 	if len(ctx.host) > 0 {
 		dumpArgs = append(dumpArgs, "--host", ctx.host)
@@ -64,36 +83,110 @@ func (ctx *MySQL) dumpArgs() []string {
 	if len(ctx.username) > 0 {
 		dumpArgs = append(dumpArgs, "-u", ctx.username)
 	}
-	if len(ctx.password) > 0 {
-		dumpArgs = append(dumpArgs, `-p`+ctx.password)
+	return dumpArgs
+}
+
+// env passes the password to mysql and mysqldump, keeping it out of the
+// process list
+func (ctx *MySQL) env() []string {
+	if len(ctx.password) == 0 {
+		return nil
 	}
+	return []string{"MYSQL_PWD=" + ctx.password}
+}
+
+func (ctx *MySQL) dumpArgs() []string {
+	dumpArgs := ctx.connArgs()
 	if len(ctx.additionalOptions) > 0 {
 		dumpArgs = append(dumpArgs, ctx.additionalOptions...)
 	}
 
 	dumpArgs = append(dumpArgs, ctx.database)
-	dumpFilePath := filepath.Join(ctx.dumpPath, ctx.database+".sql")
-	dumpArgs = append(dumpArgs, "--result-file="+dumpFilePath)
 	return dumpArgs
 }
 
-func (ctx *MySQL) dump() error {
-	slog.Info("Dumping MySQL database", 
+func (ctx *MySQL) dump(runCtx context.Context) error {
+	slog.Info("Dumping MySQL database",
 		"component", "database",
+		"model", ctx.model.Name,
+		"name", ctx.name,
 		"type", "mysql",
 		"database", ctx.database,
 		"host", ctx.host,
 		"port", ctx.port)
-	
-	_, err := helper.Exec("mysqldump", ctx.dumpArgs()...)
+
+	dumpFilePath := filepath.Join(ctx.dumpPath, ctx.database+".sql")
+	_, err := helper.ExecWithCustomEnv(runCtx, "mysqldump", ctx.env(), append(ctx.dumpArgs(), "--result-file="+dumpFilePath)...)
 	if err != nil {
 		return fmt.Errorf("-> Dump error: %s", err)
 	}
-	
-	slog.Info("MySQL dump completed", 
+
+	slog.Info("MySQL dump completed",
 		"component", "database",
+		"model", ctx.model.Name,
+		"name", ctx.name,
 		"type", "mysql",
 		"database", ctx.database,
 		"dumpPath", ctx.dumpPath)
 	return nil
 }
+
+func (ctx *MySQL) stream(runCtx context.Context, newEntry EntryFunc) error {
+	if err := ctx.load(); err != nil {
+		return err
+	}
+
+	slog.Info("Streaming MySQL dump",
+		"component", "database",
+		"model", ctx.model.Name,
+		"name", ctx.name,
+		"type", "mysql",
+		"database", ctx.database,
+		"host", ctx.host,
+		"port", ctx.port)
+
+	err := streamEntry(newEntry, ctx.entryName(ctx.database+".sql"), func(w io.Writer) error {
+		return helper.ExecStream(runCtx, "mysqldump", ctx.env(), nil, w, ctx.dumpArgs()...)
+	})
+	if err != nil {
+		return fmt.Errorf("-> Dump error: %s", err)
+	}
+	return nil
+}
+
+func (ctx *MySQL) restore(runCtx context.Context, opts RestoreOptions) error {
+	if err := ctx.load(); err != nil {
+		return err
+	}
+	if len(opts.Host) > 0 {
+		ctx.host = opts.Host
+	}
+
+	dumpFilePath := filepath.Join(ctx.dumpPath, ctx.database+".sql")
+	if !helper.IsExistsPath(dumpFilePath) {
+		return fmt.Errorf("dump file %s not found", dumpFilePath)
+	}
+
+	slog.Info("Restoring MySQL database",
+		"component", "database",
+		"model", ctx.model.Name,
+		"name", ctx.name,
+		"type", "mysql",
+		"database", ctx.database,
+		"host", ctx.host,
+		"port", ctx.port)
+
+	args := ctx.connArgs()
+	args = append(args, ctx.database, "-e", "source "+dumpFilePath)
+	if _, err := helper.ExecWithCustomEnv(runCtx, "mysql", ctx.env(), args...); err != nil {
+		return fmt.Errorf("-> Restore error: %s", err)
+	}
+
+	slog.Info("MySQL restore completed",
+		"component", "database",
+		"model", ctx.model.Name,
+		"name", ctx.name,
+		"type", "mysql",
+		"database", ctx.database)
+	return nil
+}
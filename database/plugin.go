@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/holgerhuo/gobackup/plugin"
+)
+
+// pluginDatabase runs a database type of an exec plugin, the dump is a
+// single file named after the database with the ext the plugin describes,
+// default to .dump
+type pluginDatabase struct {
+	plugin   *plugin.Plugin
+	model    string
+	name     string
+	settings map[string]interface{}
+	fileName string
+}
+
+func pluginFactory(p *plugin.Plugin) Factory {
+	return func(model, name string, settings map[string]interface{}) (Database, error) {
+		desc, err := p.Describe()
+		if err != nil {
+			return nil, err
+		}
+		ext := desc.Ext
+		if len(ext) == 0 {
+			ext = ".dump"
+		}
+		return &pluginDatabase{
+			plugin:   p,
+			model:    model,
+			name:     name,
+			settings: settings,
+			fileName: name + ext,
+		}, nil
+	}
+}
+
+func (db *pluginDatabase) request(method string) plugin.Request {
+	req := db.plugin.NewRequest(method)
+	req.Model = db.model
+	req.Name = db.name
+	req.Settings = db.settings
+	return req
+}
+
+func (db *pluginDatabase) Dump(ctx context.Context, newEntry EntryFunc) error {
+	return streamEntry(newEntry, db.fileName, func(w io.Writer) error {
+		return db.plugin.Call(ctx, db.request("dump"), nil, w)
+	})
+}
+
+func (db *pluginDatabase) Restore(ctx context.Context, dir string, opts RestoreOptions) error {
+	file, err := os.Open(filepath.Join(dir, db.fileName))
+	if err != nil {
+		return fmt.Errorf("dump file of %s not found: %s", db.name, err)
+	}
+	defer file.Close()
+
+	req := db.request("restore")
+	req.Options = map[string]string{
+		"host":       opts.Host,
+		"target_dir": opts.TargetDir,
+	}
+	return db.plugin.Call(ctx, req, file, nil)
+}
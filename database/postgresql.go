@@ -1,12 +1,14 @@
 package database
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"log/slog"
-	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/holgerhuo/gobackup/config"
 	"github.com/holgerhuo/gobackup/helper"
 )
 
@@ -28,7 +30,16 @@ type PostgreSQL struct {
 	dumpCommand string
 }
 
-func (ctx PostgreSQL) perform() (err error) {
+func init() {
+	register("postgresql", func(base Base) (Context, error) {
+		return &PostgreSQL{Base: base}, nil
+	}, config.Schema{
+		Required: []string{"database"},
+		Optional: []string{"host", "port", "username", "password"},
+	})
+}
+
+func (ctx *PostgreSQL) load() {
 	viper := ctx.viper
 	viper.SetDefault("host", "localhost")
 	viper.SetDefault("port", 5432)
@@ -38,21 +49,21 @@ func (ctx PostgreSQL) perform() (err error) {
 	ctx.database = viper.GetString("database")
 	ctx.username = viper.GetString("username")
 	ctx.password = viper.GetString("password")
+}
+
+func (ctx PostgreSQL) perform(runCtx context.Context) (err error) {
+	ctx.load()
 
 	if err = ctx.prepare(); err != nil {
 		return
 	}
 
-	err = ctx.dump()
+	err = ctx.dump(runCtx)
 	return
 }
 
-func (ctx *PostgreSQL) prepare() (err error) {
-	// mysqldump command
+func (ctx *PostgreSQL) connArgs() []string {
 	dumpArgs := []string{}
-	if len(ctx.database) == 0 {
-		return fmt.Errorf("PostgreSQL database config is required")
-	}
 	if len(ctx.host) > 0 {
 		dumpArgs = append(dumpArgs, "--host="+ctx.host)
 	}
@@ -62,7 +73,24 @@ func (ctx *PostgreSQL) prepare() (err error) {
 	if len(ctx.username) > 0 {
 		dumpArgs = append(dumpArgs, "--username="+ctx.username)
 	}
+	return dumpArgs
+}
 
+// env passes the password to pg_dump and pg_restore without touching the
+// environment of gobackup itself
+func (ctx *PostgreSQL) env() []string {
+	if len(ctx.password) == 0 {
+		return nil
+	}
+	return []string{"PGPASSWORD=" + ctx.password}
+}
+
+func (ctx *PostgreSQL) prepare() (err error) {
+	// pg_dump command
+	if len(ctx.database) == 0 {
+		return fmt.Errorf("PostgreSQL database config is required")
+	}
+	dumpArgs := ctx.connArgs()
 	dumpArgs = append(dumpArgs, "-Fc --compress=0")
 
 	ctx.dumpCommand = "pg_dump " + strings.Join(dumpArgs, " ") + " " + ctx.database
@@ -70,34 +98,94 @@ func (ctx *PostgreSQL) prepare() (err error) {
 	return nil
 }
 
-func (ctx *PostgreSQL) dump() error {
+func (ctx *PostgreSQL) dump(runCtx context.Context) error {
 	dumpFilePath := filepath.Join(ctx.dumpPath, ctx.database+".dump")
-	
-	slog.Info("Dumping PostgreSQL database", 
+
+	slog.Info("Dumping PostgreSQL database",
 		"component", "database",
+		"model", ctx.model.Name,
+		"name", ctx.name,
 		"type", "postgresql",
 		"database", ctx.database,
 		"host", ctx.host,
 		"port", ctx.port)
-	
-	if len(ctx.password) > 0 {
-		os.Setenv("PGPASSWORD", ctx.password)
-	}
-	
-	_, err := helper.Exec(ctx.dumpCommand, "-f", dumpFilePath)
+
+	_, err := helper.ExecWithCustomEnv(runCtx, ctx.dumpCommand, ctx.env(), "-f", dumpFilePath)
 	if err != nil {
 		slog.Error("PostgreSQL dump failed",
 			"component", "database",
+			"model", ctx.model.Name,
+			"name", ctx.name,
 			"type", "postgresql",
 			"database", ctx.database,
 			"error", err)
 		return err
 	}
-	
-	slog.Info("PostgreSQL dump completed", 
+
+	slog.Info("PostgreSQL dump completed",
 		"component", "database",
+		"model", ctx.model.Name,
+		"name", ctx.name,
 		"type", "postgresql",
 		"database", ctx.database,
 		"dumpPath", dumpFilePath)
 	return nil
 }
+
+func (ctx *PostgreSQL) stream(runCtx context.Context, newEntry EntryFunc) (err error) {
+	ctx.load()
+	if err = ctx.prepare(); err != nil {
+		return
+	}
+
+	slog.Info("Streaming PostgreSQL dump",
+		"component", "database",
+		"model", ctx.model.Name,
+		"name", ctx.name,
+		"type", "postgresql",
+		"database", ctx.database,
+		"host", ctx.host,
+		"port", ctx.port)
+
+	return streamEntry(newEntry, ctx.entryName(ctx.database+".dump"), func(w io.Writer) error {
+		return helper.ExecStream(runCtx, ctx.dumpCommand, ctx.env(), nil, w)
+	})
+}
+
+func (ctx *PostgreSQL) restore(runCtx context.Context, opts RestoreOptions) error {
+	ctx.load()
+	if len(ctx.database) == 0 {
+		return fmt.Errorf("PostgreSQL database config is required")
+	}
+	if len(opts.Host) > 0 {
+		ctx.host = opts.Host
+	}
+
+	dumpFilePath := filepath.Join(ctx.dumpPath, ctx.database+".dump")
+	if !helper.IsExistsPath(dumpFilePath) {
+		return fmt.Errorf("dump file %s not found", dumpFilePath)
+	}
+
+	slog.Info("Restoring PostgreSQL database",
+		"component", "database",
+		"model", ctx.model.Name,
+		"name", ctx.name,
+		"type", "postgresql",
+		"database", ctx.database,
+		"host", ctx.host,
+		"port", ctx.port)
+
+	args := ctx.connArgs()
+	args = append(args, "--dbname="+ctx.database, "--clean", "--if-exists", dumpFilePath)
+	if _, err := helper.ExecWithCustomEnv(runCtx, "pg_restore", ctx.env(), args...); err != nil {
+		return err
+	}
+
+	slog.Info("PostgreSQL restore completed",
+		"component", "database",
+		"model", ctx.model.Name,
+		"name", ctx.name,
+		"type", "postgresql",
+		"database", ctx.database)
+	return nil
+}
@@ -1,12 +1,16 @@
 package database
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"log/slog"
+	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
 
+	"github.com/holgerhuo/gobackup/config"
 	"github.com/holgerhuo/gobackup/helper"
 )
 
@@ -34,14 +38,19 @@ type Redis struct {
 	mode       redisMode
 	invokeSave bool
 	// path of rdb file, example: /var/lib/redis/dump.rdb
-	rdbPath string
+	rdbPath    string
+	cliCommand string
 }
 
-var (
-	redisCliCommand = "redis-cli"
-)
+func init() {
+	register("redis", func(base Base) (Context, error) {
+		return &Redis{Base: base}, nil
+	}, config.Schema{
+		Optional: []string{"mode", "invoke_save", "host", "port", "password", "rdb_path"},
+	})
+}
 
-func (ctx *Redis) perform() (err error) {
+func (ctx *Redis) load() {
 	viper := ctx.viper
 	viper.SetDefault("rdb_path", "/var/db/redis/dump.rdb")
 	viper.SetDefault("host", "127.0.0.1")
@@ -59,30 +68,36 @@ func (ctx *Redis) perform() (err error) {
 		ctx.mode = redisModeSync
 	} else {
 		ctx.mode = redisModeCopy
+	}
+}
 
-		if !helper.IsExistsPath(ctx.rdbPath) {
-			return fmt.Errorf("Redis RDB file: %s does not exist", ctx.rdbPath)
-		}
+func (ctx *Redis) perform(runCtx context.Context) (err error) {
+	ctx.load()
+
+	if ctx.mode == redisModeCopy && !helper.IsExistsPath(ctx.rdbPath) {
+		return fmt.Errorf("Redis RDB file: %s does not exist", ctx.rdbPath)
 	}
 
 	if err = ctx.prepare(); err != nil {
 		return
 	}
 
-	slog.Info("Invoking Redis SAVE command", 
+	slog.Info("Invoking Redis SAVE command",
 		"component", "database",
+		"model", ctx.model.Name,
+		"name", ctx.name,
 		"type", "redis",
 		"host", ctx.host,
 		"port", ctx.port)
-	
-	if err = ctx.save(); err != nil {
+
+	if err = ctx.save(runCtx); err != nil {
 		return
 	}
 
 	if ctx.mode == redisModeCopy {
-		err = ctx.copy()
+		err = ctx.copy(runCtx)
 	} else {
-		err = ctx.sync()
+		err = ctx.sync(runCtx)
 	}
 	if err != nil {
 		return
@@ -100,28 +115,37 @@ func (ctx *Redis) prepare() error {
 	if len(ctx.port) > 0 {
 		args = append(args, "-p "+ctx.port)
 	}
-	if len(ctx.password) > 0 {
-		args = append(args, `-a `+ctx.password)
-	}
-	redisCliCommand = strings.Join(args, " ")
+	ctx.cliCommand = strings.Join(args, " ")
 
 	return nil
 }
 
-func (ctx *Redis) save() error {
+// env passes the password to redis-cli, keeping it out of the process list
+func (ctx *Redis) env() []string {
+	if len(ctx.password) == 0 {
+		return nil
+	}
+	return []string{"REDISCLI_AUTH=" + ctx.password}
+}
+
+func (ctx *Redis) save(runCtx context.Context) error {
 	if !ctx.invokeSave {
 		return nil
 	}
-	// FIXME: add retry
-	slog.Info("Performing Redis SAVE command", 
+	slog.Info("Performing Redis SAVE command",
 		"component", "database",
+		"model", ctx.model.Name,
+		"name", ctx.name,
 		"type", "redis",
-		"command", redisCliCommand+" SAVE")
-	
-	out, err := helper.Exec(redisCliCommand, "SAVE")
+		"command", ctx.cliCommand+" SAVE")
+
+	out, err := helper.ExecWithCustomEnv(runCtx, ctx.cliCommand, ctx.env(), "SAVE")
 	if err != nil {
 		return fmt.Errorf("redis-cli SAVE failed %s", err)
 	}
+	if helper.IsDryRun(runCtx) {
+		return nil
+	}
 
 	if !regexp.MustCompile("OK$").MatchString(strings.TrimSpace(out)) {
 		return fmt.Errorf(`Failed to invoke the "SAVE" command Response was: %s`, out)
@@ -130,17 +154,22 @@ func (ctx *Redis) save() error {
 	return nil
 }
 
-func (ctx *Redis) sync() error {
+func (ctx *Redis) sync(runCtx context.Context) error {
 	dumpFilePath := filepath.Join(ctx.dumpPath, "dump.rdb")
-	slog.Info("Syncing Redis dump file", 
+	slog.Info("Syncing Redis dump file",
 		"component", "database",
+		"model", ctx.model.Name,
+		"name", ctx.name,
 		"type", "redis",
 		"dumpPath", dumpFilePath)
-	
-	_, err := helper.Exec(redisCliCommand, "--rdb", dumpFilePath)
+
+	_, err := helper.ExecWithCustomEnv(runCtx, ctx.cliCommand, ctx.env(), "--rdb", dumpFilePath)
 	if err != nil {
 		return fmt.Errorf("dump redis error: %s", err)
 	}
+	if helper.IsDryRun(runCtx) {
+		return nil
+	}
 
 	if !helper.IsExistsPath(dumpFilePath) {
 		return fmt.Errorf("dump result file %s not found", dumpFilePath)
@@ -149,16 +178,96 @@ func (ctx *Redis) sync() error {
 	return nil
 }
 
-func (ctx *Redis) copy() error {
-	slog.Info("Copying Redis dump file", 
+func (ctx *Redis) copy(runCtx context.Context) error {
+	slog.Info("Copying Redis dump file",
 		"component", "database",
+		"model", ctx.model.Name,
+		"name", ctx.name,
 		"type", "redis",
 		"source", ctx.rdbPath,
 		"destination", ctx.dumpPath)
-	
-	_, err := helper.Exec("cp", ctx.rdbPath, ctx.dumpPath)
+
+	_, err := helper.Exec(runCtx, "cp", ctx.rdbPath, ctx.dumpPath)
 	if err != nil {
 		return fmt.Errorf("copy redis dump file error: %s", err)
 	}
 	return nil
 }
+
+// stream sends the RDB file, or `redis-cli --rdb -` output in sync mode
+// which needs redis-cli 7.0 or later
+func (ctx *Redis) stream(runCtx context.Context, newEntry EntryFunc) (err error) {
+	ctx.load()
+	if err = ctx.prepare(); err != nil {
+		return
+	}
+	if err = ctx.save(runCtx); err != nil {
+		return
+	}
+
+	if ctx.mode == redisModeSync {
+		slog.Info("Streaming Redis dump",
+			"component", "database",
+			"model", ctx.model.Name,
+			"name", ctx.name,
+			"type", "redis",
+			"host", ctx.host,
+			"port", ctx.port)
+		return streamEntry(newEntry, ctx.entryName("dump.rdb"), func(w io.Writer) error {
+			return helper.ExecStream(runCtx, ctx.cliCommand, ctx.env(), nil, w, "--rdb", "-")
+		})
+	}
+
+	slog.Info("Streaming Redis dump file",
+		"component", "database",
+		"model", ctx.model.Name,
+		"name", ctx.name,
+		"type", "redis",
+		"source", ctx.rdbPath)
+	if helper.IsDryRun(runCtx) {
+		return nil
+	}
+	return streamEntry(newEntry, ctx.entryName(filepath.Base(ctx.rdbPath)), func(w io.Writer) error {
+		file, err := os.Open(ctx.rdbPath)
+		if err != nil {
+			return fmt.Errorf("open redis dump file error: %s", err)
+		}
+		defer file.Close()
+		_, err = io.Copy(w, file)
+		return err
+	})
+}
+
+// restore places the RDB file where redis loads it on start,
+// redis must be stopped while the file is replaced.
+func (ctx *Redis) restore(runCtx context.Context, opts RestoreOptions) error {
+	ctx.load()
+
+	dumpFilePath := filepath.Join(ctx.dumpPath, "dump.rdb")
+	if ctx.mode == redisModeCopy {
+		dumpFilePath = filepath.Join(ctx.dumpPath, filepath.Base(ctx.rdbPath))
+	}
+	if !helper.IsExistsPath(dumpFilePath) {
+		return fmt.Errorf("dump file %s not found", dumpFilePath)
+	}
+
+	targetDir := opts.TargetDir
+	if len(targetDir) == 0 {
+		targetDir = filepath.Dir(ctx.rdbPath)
+	}
+	helper.MkdirP(targetDir)
+	targetPath := filepath.Join(targetDir, filepath.Base(ctx.rdbPath))
+
+	slog.Warn("Placing Redis RDB file, make sure redis is stopped",
+		"component", "database",
+		"model", ctx.model.Name,
+		"name", ctx.name,
+		"type", "redis",
+		"source", dumpFilePath,
+		"destination", targetPath)
+
+	if _, err := helper.Exec(runCtx, "cp", dumpFilePath, targetPath); err != nil {
+		return fmt.Errorf("copy redis dump file error: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+//go:build !windows
+
+package database
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/holgerhuo/gobackup/config"
+	"github.com/spf13/viper"
+)
+
+// stubCommand puts a fake command in PATH that writes its arguments and the
+// password variables it got into a file
+func stubCommand(t *testing.T, name string) string {
+	binDir := t.TempDir()
+	outPath := filepath.Join(t.TempDir(), name+".out")
+	script := "#!/bin/sh\n" +
+		"echo \"args: $*\" > " + outPath + "\n" +
+		"echo \"env: $PGPASSWORD$MYSQL_PWD\" >> " + outPath + "\n"
+	if err := os.WriteFile(filepath.Join(binDir, name), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("PGPASSWORD", "")
+	t.Setenv("MYSQL_PWD", "")
+	return outPath
+}
+
+func TestRestorePassword(t *testing.T) {
+	const password = "s3cret-pass"
+
+	cases := []struct {
+		typ     string
+		command string
+		dump    string
+	}{
+		{typ: "postgresql", command: "pg_restore", dump: "app.dump"},
+		{typ: "mysql", command: "mysql", dump: "app.sql"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.typ, func(t *testing.T) {
+			outPath := stubCommand(t, c.command)
+
+			v := viper.New()
+			v.Set("database", "app")
+			v.Set("password", password)
+			model := config.ModelConfig{Name: "test", DumpPath: t.TempDir()}
+			ctx, err := newContext(model, config.SubConfig{Name: "app", Type: c.typ, Viper: v})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			dumpDir := filepath.Join(model.DumpPath, c.typ, "app")
+			os.MkdirAll(dumpDir, 0700)
+			if err = os.WriteFile(filepath.Join(dumpDir, c.dump), nil, 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			if err = ctx.restore(context.Background(), RestoreOptions{}); err != nil {
+				t.Fatal(err)
+			}
+
+			out, err := os.ReadFile(outPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+			if len(lines) != 2 {
+				t.Fatalf("unexpected output %q", out)
+			}
+			if strings.Contains(lines[0], password) {
+				t.Errorf("password in arguments: %s", lines[0])
+			}
+			if lines[1] != "env: "+password {
+				t.Errorf("%s got %q, want the password in its environment", c.command, lines[1])
+			}
+			if os.Getenv("PGPASSWORD") != "" || os.Getenv("MYSQL_PWD") != "" {
+				t.Errorf("password leaked into the environment of gobackup")
+			}
+		})
+	}
+}
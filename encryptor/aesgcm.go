@@ -0,0 +1,364 @@
+package encryptor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/holgerhuo/gobackup/config"
+	"github.com/holgerhuo/gobackup/helper"
+)
+
+// AESGCM encryptor, built in chunked AES-256-GCM with scrypt key derivation
+//
+// type: aes-gcm
+// password:
+// scrypt_log_n: 16 # scrypt cost N = 2^16, stored in the header
+//
+// File format (.aes), all integers big endian:
+//
+//	magic         8 bytes  "GBAESGCM"
+//	version       1 byte   1
+//	scrypt log2 N 1 byte
+//	scrypt r      1 byte
+//	scrypt p      1 byte
+//	salt          16 bytes
+//	nonce prefix  7 bytes  random
+//	chunk size    4 bytes  plaintext bytes per chunk
+//	chunks        ...      AES-256-GCM sealed, chunk size + 16 bytes each,
+//	                       the last one may be shorter
+//
+// The key is scrypt(password, salt, N, r, p, 32). The nonce of chunk i is
+// nonce prefix || uint32(i) || last, where last is 1 for the final chunk and 0
+// otherwise, so reordered, dropped or truncated chunks fail to open. The
+// whole header is the additional data of every chunk.
+type AESGCM struct {
+	Base
+	password string
+	logN     int
+}
+
+func init() {
+	register("aes-gcm", func(base Base) (Context, error) {
+		return &AESGCM{Base: base}, nil
+	}, config.Schema{
+		Required: []string{"password"},
+		Optional: []string{"scrypt_log_n"},
+	})
+}
+
+const (
+	aesMagic       = "GBAESGCM"
+	aesVersion     = 1
+	aesSaltSize    = 16
+	aesPrefixSize  = 7
+	aesHeaderSize  = len(aesMagic) + 4 + aesSaltSize + aesPrefixSize + 4
+	aesChunkSize   = 64 << 10
+	aesScryptR     = 8
+	aesScryptP     = 1
+	aesMinLogN     = 10
+	aesMaxLogN     = 22
+	aesMaxChunk    = 16 << 20
+	aesKeySize     = 32
+	aesDefaultLogN = 16
+)
+
+func (ctx *AESGCM) load() error {
+	ctx.viper.SetDefault("scrypt_log_n", aesDefaultLogN)
+	ctx.password = ctx.viper.GetString("password")
+	ctx.logN = ctx.viper.GetInt("scrypt_log_n")
+
+	if len(ctx.password) == 0 {
+		return fmt.Errorf("password option is required")
+	}
+	if ctx.logN < aesMinLogN || ctx.logN > aesMaxLogN {
+		return fmt.Errorf("scrypt_log_n must be between %d and %d", aesMinLogN, aesMaxLogN)
+	}
+	return nil
+}
+
+func (ctx *AESGCM) ext() string {
+	return ".aes"
+}
+
+func (ctx *AESGCM) perform(runCtx context.Context) (encryptPath string, err error) {
+	if err = ctx.load(); err != nil {
+		return
+	}
+
+	encryptPath = ctx.archivePath + ctx.ext()
+	err = transformFile(runCtx, ctx.archivePath, encryptPath, func(w io.Writer) (io.WriteCloser, error) {
+		return ctx.newWriter(runCtx, w)
+	}, nil)
+	return
+}
+
+func (ctx *AESGCM) decrypt(runCtx context.Context) (archivePath string, err error) {
+	if err = ctx.load(); err != nil {
+		return
+	}
+
+	archivePath = strings.TrimSuffix(ctx.archivePath, ctx.ext())
+	if archivePath == ctx.archivePath {
+		archivePath = ctx.archivePath + ".dec"
+	}
+	err = transformFile(runCtx, ctx.archivePath, archivePath, nil, func(r io.Reader) (io.Reader, error) {
+		return newAESReader(r, ctx.password)
+	})
+	return
+}
+
+func (ctx *AESGCM) newWriter(runCtx context.Context, w io.Writer) (io.WriteCloser, error) {
+	if err := ctx.load(); err != nil {
+		return nil, err
+	}
+	return newAESWriter(w, ctx.password, ctx.logN)
+}
+
+// transformFile copies inPath to outPath through a writer or a reader,
+// reading stops once runCtx is done
+func transformFile(runCtx context.Context, inPath, outPath string, newWriter func(io.Writer) (io.WriteCloser, error), newReader func(io.Reader) (io.Reader, error)) error {
+	in, err := os.Open(inPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(outPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	r := helper.NewContextReader(runCtx, in)
+	var w io.Writer = out
+	var wc io.WriteCloser
+	if newReader != nil {
+		if r, err = newReader(r); err != nil {
+			return err
+		}
+	}
+	if newWriter != nil {
+		if wc, err = newWriter(out); err != nil {
+			return err
+		}
+		w = wc
+	}
+
+	if _, err = io.Copy(w, r); err != nil {
+		os.Remove(outPath)
+		return err
+	}
+	if wc != nil {
+		if err = wc.Close(); err != nil {
+			os.Remove(outPath)
+			return err
+		}
+	}
+	return out.Close()
+}
+
+func aesCipher(password string, salt []byte, logN, r, p int) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(password), salt, 1<<logN, r, p, aesKeySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func aesNonce(prefix []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[aesPrefixSize:], counter)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+type aesWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	header  []byte
+	prefix  []byte
+	buf     []byte
+	counter uint32
+	closed  bool
+}
+
+func newAESWriter(w io.Writer, password string, logN int) (*aesWriter, error) {
+	header := make([]byte, 0, aesHeaderSize)
+	header = append(header, aesMagic...)
+	header = append(header, aesVersion, byte(logN), aesScryptR, aesScryptP)
+
+	random := make([]byte, aesSaltSize+aesPrefixSize)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	salt, prefix := random[:aesSaltSize], random[aesSaltSize:]
+	header = append(header, random...)
+	header = binary.BigEndian.AppendUint32(header, aesChunkSize)
+
+	aead, err := aesCipher(password, salt, logN, aesScryptR, aesScryptP)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &aesWriter{
+		w:      w,
+		aead:   aead,
+		header: header,
+		prefix: prefix,
+		buf:    make([]byte, 0, aesChunkSize),
+	}, nil
+}
+
+func (a *aesWriter) Write(p []byte) (n int, err error) {
+	if a.closed {
+		return 0, errors.New("write to closed aes writer")
+	}
+	for len(p) > 0 {
+		// a full chunk is only sealed once more data shows it isn't the last
+		if len(a.buf) == aesChunkSize {
+			if err = a.seal(false); err != nil {
+				return
+			}
+		}
+		size := min(aesChunkSize-len(a.buf), len(p))
+		a.buf = append(a.buf, p[:size]...)
+		p = p[size:]
+		n += size
+	}
+	return
+}
+
+func (a *aesWriter) seal(last bool) error {
+	if a.counter == ^uint32(0) {
+		return errors.New("aes stream too large")
+	}
+	out := a.aead.Seal(nil, aesNonce(a.prefix, a.counter, last), a.buf, a.header)
+	a.counter++
+	a.buf = a.buf[:0]
+	_, err := a.w.Write(out)
+	return err
+}
+
+// Close seals the last chunk, it doesn't close the underlying writer
+func (a *aesWriter) Close() error {
+	if a.closed {
+		return nil
+	}
+	a.closed = true
+	return a.seal(true)
+}
+
+type aesReader struct {
+	r         *bufio.Reader
+	aead      cipher.AEAD
+	header    []byte
+	prefix    []byte
+	chunkSize int
+	counter   uint32
+	plain     []byte
+	done      bool
+}
+
+func newAESReader(r io.Reader, password string) (*aesReader, error) {
+	header := make([]byte, aesHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("read aes header failed: %s", err)
+	}
+	if !bytes.Equal(header[:len(aesMagic)], []byte(aesMagic)) {
+		return nil, errors.New("not an aes-gcm backup")
+	}
+
+	offset := len(aesMagic)
+	version, logN, scryptR, scryptP := header[offset], int(header[offset+1]), int(header[offset+2]), int(header[offset+3])
+	if version != aesVersion {
+		return nil, fmt.Errorf("unsupported aes-gcm version %d", version)
+	}
+	// only the parameters the writer uses, a tampered header could demand
+	// gigabytes of scrypt memory otherwise
+	if logN < aesMinLogN || logN > aesMaxLogN || scryptR != aesScryptR || scryptP != aesScryptP {
+		return nil, errors.New("invalid aes-gcm scrypt parameters")
+	}
+	offset += 4
+	salt := header[offset : offset+aesSaltSize]
+	offset += aesSaltSize
+	prefix := header[offset : offset+aesPrefixSize]
+	offset += aesPrefixSize
+	chunkSize := int(binary.BigEndian.Uint32(header[offset:]))
+	if chunkSize == 0 || chunkSize > aesMaxChunk {
+		return nil, errors.New("invalid aes-gcm chunk size")
+	}
+
+	aead, err := aesCipher(password, salt, logN, scryptR, scryptP)
+	if err != nil {
+		return nil, err
+	}
+
+	return &aesReader{
+		r:         bufio.NewReaderSize(r, chunkSize+aead.Overhead()+1),
+		aead:      aead,
+		header:    header,
+		prefix:    prefix,
+		chunkSize: chunkSize,
+	}, nil
+}
+
+func (a *aesReader) Read(p []byte) (int, error) {
+	for len(a.plain) == 0 {
+		if a.done {
+			return 0, io.EOF
+		}
+		if err := a.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, a.plain)
+	a.plain = a.plain[n:]
+	return n, nil
+}
+
+func (a *aesReader) open() error {
+	sealed := make([]byte, a.chunkSize+a.aead.Overhead())
+	n, err := io.ReadFull(a.r, sealed)
+	last := false
+	switch {
+	case err == io.ErrUnexpectedEOF || err == io.EOF:
+		last = true
+	case err != nil:
+		return err
+	default:
+		if _, peekErr := a.r.Peek(1); peekErr == io.EOF {
+			last = true
+		}
+	}
+
+	plain, err := a.aead.Open(nil, aesNonce(a.prefix, a.counter, last), sealed[:n], a.header)
+	if err != nil {
+		return errors.New("aes-gcm authentication failed, wrong password or corrupted backup")
+	}
+	a.counter++
+	a.plain = plain
+	a.done = last
+	return nil
+}
@@ -0,0 +1,195 @@
+package encryptor
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"strings"
+	"testing"
+)
+
+const testPassword = "correct horse battery staple"
+
+func encryptAES(t *testing.T, plain []byte) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	w, err := newAESWriter(buf, testPassword, aesMinLogN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// odd write sizes cross the chunk boundaries
+	for p := plain; len(p) > 0; {
+		n := min(len(p), 1000)
+		if _, err = w.Write(p[:n]); err != nil {
+			t.Fatal(err)
+		}
+		p = p[n:]
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decryptAES(data []byte, password string) ([]byte, error) {
+	r, err := newAESReader(bytes.NewReader(data), password)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestAESRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 1, aesChunkSize - 1, aesChunkSize, aesChunkSize + 1, 3*aesChunkSize + 5} {
+		plain := make([]byte, size)
+		rand.Read(plain)
+
+		data := encryptAES(t, plain)
+		chunks := max((size+aesChunkSize-1)/aesChunkSize, 1)
+		if want := aesHeaderSize + size + chunks*16; len(data) != want {
+			t.Errorf("size %d: encrypted %d bytes, want %d", size, len(data), want)
+		}
+
+		got, err := decryptAES(data, testPassword)
+		if err != nil {
+			t.Fatalf("size %d: decrypt error = %v", size, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Fatalf("size %d: decrypted data differs", size)
+		}
+	}
+}
+
+func TestAESTampered(t *testing.T) {
+	plain := make([]byte, 2*aesChunkSize+100)
+	rand.Read(plain)
+	data := encryptAES(t, plain)
+	sealedChunk := aesChunkSize + 16
+	offset := len(aesMagic)
+
+	cases := []struct {
+		name     string
+		data     func() []byte
+		password string
+		wantErr  string
+	}{
+		{
+			name:     "wrong password",
+			data:     func() []byte { return data },
+			password: "wrong",
+			wantErr:  "authentication failed",
+		},
+		{
+			name:    "bad magic",
+			data:    func() []byte { return flip(data, 0) },
+			wantErr: "not an aes-gcm backup",
+		},
+		{
+			name:    "bad version",
+			data:    func() []byte { return set(data, offset, 2) },
+			wantErr: "unsupported aes-gcm version",
+		},
+		{
+			name:    "huge scrypt N",
+			data:    func() []byte { return set(data, offset+1, 40) },
+			wantErr: "invalid aes-gcm scrypt parameters",
+		},
+		{
+			name:    "tiny scrypt N",
+			data:    func() []byte { return set(data, offset+1, 1) },
+			wantErr: "invalid aes-gcm scrypt parameters",
+		},
+		{
+			name:    "huge scrypt r",
+			data:    func() []byte { return set(data, offset+2, 255) },
+			wantErr: "invalid aes-gcm scrypt parameters",
+		},
+		{
+			name:    "huge scrypt p",
+			data:    func() []byte { return set(data, offset+3, 255) },
+			wantErr: "invalid aes-gcm scrypt parameters",
+		},
+		{
+			name:    "huge chunk size",
+			data:    func() []byte { return set(data, aesHeaderSize-4, 0xff) },
+			wantErr: "invalid aes-gcm chunk size",
+		},
+		{
+			name:    "changed salt",
+			data:    func() []byte { return flip(data, offset+4) },
+			wantErr: "authentication failed",
+		},
+		{
+			name:    "changed nonce prefix",
+			data:    func() []byte { return flip(data, offset+4+aesSaltSize) },
+			wantErr: "authentication failed",
+		},
+		{
+			name:    "flipped ciphertext",
+			data:    func() []byte { return flip(data, aesHeaderSize+sealedChunk+10) },
+			wantErr: "authentication failed",
+		},
+		{
+			name:    "truncated header",
+			data:    func() []byte { return data[:aesHeaderSize-1] },
+			wantErr: "read aes header failed",
+		},
+		{
+			name:    "header only",
+			data:    func() []byte { return data[:aesHeaderSize] },
+			wantErr: "authentication failed",
+		},
+		{
+			name:    "dropped last chunk",
+			data:    func() []byte { return data[:aesHeaderSize+2*sealedChunk] },
+			wantErr: "authentication failed",
+		},
+		{
+			name:    "truncated last chunk",
+			data:    func() []byte { return data[:len(data)-1] },
+			wantErr: "authentication failed",
+		},
+		{
+			name: "swapped chunks",
+			data: func() []byte {
+				swapped := bytes.Clone(data)
+				first := data[aesHeaderSize : aesHeaderSize+sealedChunk]
+				second := data[aesHeaderSize+sealedChunk : aesHeaderSize+2*sealedChunk]
+				copy(swapped[aesHeaderSize:], second)
+				copy(swapped[aesHeaderSize+sealedChunk:], first)
+				return swapped
+			},
+			wantErr: "authentication failed",
+		},
+		{
+			name:    "appended data",
+			data:    func() []byte { return append(bytes.Clone(data), 0) },
+			wantErr: "authentication failed",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			password := c.password
+			if len(password) == 0 {
+				password = testPassword
+			}
+			_, err := decryptAES(c.data(), password)
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Fatalf("decrypt error = %v, want %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func flip(data []byte, i int) []byte {
+	data = bytes.Clone(data)
+	data[i] ^= 0x01
+	return data
+}
+
+func set(data []byte, i int, b byte) []byte {
+	data = bytes.Clone(data)
+	data[i] = b
+	return data
+}
@@ -0,0 +1,138 @@
+package encryptor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+	"github.com/holgerhuo/gobackup/config"
+	"github.com/holgerhuo/gobackup/helper"
+)
+
+// Age encryptor, encrypts to public keys so hosts never hold a secret
+//
+// type: age
+// recipients: # age1... or ssh-ed25519 AAAA... public keys
+// recipients_file: # one recipient per line, # comments allowed
+// identity_file: # private key, only needed to restore
+type Age struct {
+	Base
+	recipients []age.Recipient
+}
+
+func init() {
+	register("age", func(base Base) (Context, error) {
+		return &Age{Base: base}, nil
+	}, config.Schema{
+		Optional: []string{"recipients", "recipients_file", "identity_file"},
+	})
+}
+
+func (ctx *Age) load() error {
+	lines := ctx.viper.GetStringSlice("recipients")
+	if file := ctx.viper.GetString("recipients_file"); len(file) > 0 {
+		data, err := os.ReadFile(helper.ExplandHome(file))
+		if err != nil {
+			return fmt.Errorf("read recipients_file failed: %s", err)
+		}
+		lines = append(lines, strings.Split(string(data), "\n")...)
+	}
+
+	ctx.recipients = nil
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		recipient, err := parseAgeRecipient(line)
+		if err != nil {
+			return err
+		}
+		ctx.recipients = append(ctx.recipients, recipient)
+	}
+	return nil
+}
+
+func parseAgeRecipient(s string) (age.Recipient, error) {
+	if strings.HasPrefix(s, "age1") {
+		return age.ParseX25519Recipient(s)
+	}
+	if strings.HasPrefix(s, "ssh-") {
+		return agessh.ParseRecipient(s)
+	}
+	return nil, fmt.Errorf("unknown age recipient %q", s)
+}
+
+func (ctx *Age) ext() string {
+	return ".age"
+}
+
+func (ctx *Age) perform(runCtx context.Context) (encryptPath string, err error) {
+	if err = ctx.load(); err != nil {
+		return
+	}
+
+	encryptPath = ctx.archivePath + ctx.ext()
+	err = transformFile(runCtx, ctx.archivePath, encryptPath, ctx.encrypt, nil)
+	return
+}
+
+func (ctx *Age) newWriter(runCtx context.Context, w io.Writer) (io.WriteCloser, error) {
+	if err := ctx.load(); err != nil {
+		return nil, err
+	}
+	return ctx.encrypt(w)
+}
+
+func (ctx *Age) encrypt(w io.Writer) (io.WriteCloser, error) {
+	if len(ctx.recipients) == 0 {
+		return nil, fmt.Errorf("recipients or recipients_file option is required")
+	}
+	return age.Encrypt(w, ctx.recipients...)
+}
+
+func (ctx *Age) decrypt(runCtx context.Context) (archivePath string, err error) {
+	if err = ctx.load(); err != nil {
+		return
+	}
+	identities, err := ctx.identities()
+	if err != nil {
+		return
+	}
+
+	archivePath = strings.TrimSuffix(ctx.archivePath, ctx.ext())
+	if archivePath == ctx.archivePath {
+		archivePath = ctx.archivePath + ".dec"
+	}
+	err = transformFile(runCtx, ctx.archivePath, archivePath, nil, func(r io.Reader) (io.Reader, error) {
+		return age.Decrypt(r, identities...)
+	})
+	return
+}
+
+// identities reads age X25519 keys, or an unencrypted OpenSSH private key
+func (ctx *Age) identities() ([]age.Identity, error) {
+	identityFile := ctx.identityFile()
+	if len(identityFile) == 0 {
+		return nil, fmt.Errorf("identity_file option is required to decrypt")
+	}
+	data, err := os.ReadFile(helper.ExplandHome(identityFile))
+	if err != nil {
+		return nil, fmt.Errorf("read identity_file failed: %s", err)
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN")) {
+		identity, err := agessh.ParseIdentity(data)
+		if err != nil {
+			return nil, fmt.Errorf("parse ssh identity failed: %s", err)
+		}
+		return []age.Identity{identity}, nil
+	}
+	return age.ParseIdentities(bufio.NewReader(bytes.NewReader(data)))
+}
@@ -0,0 +1,63 @@
+package encryptor
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/holgerhuo/gobackup/config"
+)
+
+func TestDecryptIdentityKeepsConfig(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	identityPath := filepath.Join(dir, "key.txt")
+	if err = os.WriteFile(identityPath, []byte(identity.String()+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	model, err := config.NewModel("app", map[string]interface{}{
+		"encrypt_with": map[string]interface{}{
+			"type":       "age",
+			"recipients": []interface{}{identity.Recipient().String()},
+		},
+		"store_with": map[string]interface{}{"type": "local", "path": dir},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	w, err := NewWriter(context.Background(), model, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("dump"))
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	encryptPath := filepath.Join(dir, "backup.tar.age")
+	if err = os.WriteFile(encryptPath, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = Decrypt(context.Background(), encryptPath, model, ""); err == nil {
+		t.Fatal("Decrypt() without identity error = nil")
+	}
+	archivePath, err := Decrypt(context.Background(), encryptPath, model, identityPath)
+	if err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+	if got, _ := os.ReadFile(archivePath); string(got) != "dump" {
+		t.Errorf("decrypted = %q, want dump", got)
+	}
+	if got := model.EncryptWith.Viper.GetString("identity_file"); got != "" {
+		t.Errorf("identity_file = %q after Decrypt, want it unchanged", got)
+	}
+}
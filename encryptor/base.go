@@ -1,9 +1,14 @@
 package encryptor
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"log/slog"
 
 	"github.com/holgerhuo/gobackup/config"
+	"github.com/holgerhuo/gobackup/helper"
+	"github.com/holgerhuo/gobackup/plugin"
 	"github.com/spf13/viper"
 )
 
@@ -12,13 +17,26 @@ type Base struct {
 	model       config.ModelConfig
 	viper       *viper.Viper
 	archivePath string
+	// identity overrides identity_file on decrypt
+	identity string
 }
 
 // Context encryptor interface
 type Context interface {
-	perform() (encryptPath string, err error)
+	perform(runCtx context.Context) (encryptPath string, err error)
+	decrypt(runCtx context.Context) (archivePath string, err error)
+	// ext appended to the file name of the encrypted backup
+	ext() string
+	// newWriter encrypts everything written into w
+	newWriter(runCtx context.Context, w io.Writer) (io.WriteCloser, error)
 }
 
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
 func newBase(archivePath string, model config.ModelConfig) (base Base) {
 	base = Base{
 		archivePath: archivePath,
@@ -28,28 +46,73 @@ func newBase(archivePath string, model config.ModelConfig) (base Base) {
 	return
 }
 
-// Run compressor
-func Run(archivePath string, model config.ModelConfig) (encryptPath string, err error) {
-	base := newBase(archivePath, model)
-	var ctx Context
-	switch model.EncryptWith.Type {
-	case "openssl":
-		ctx = &OpenSSL{Base: base}
-	default:
+// identityFile is the identity given to Decrypt, else identity_file
+func (base *Base) identityFile() string {
+	if len(base.identity) > 0 {
+		return base.identity
+	}
+	return base.viper.GetString("identity_file")
+}
+
+// newContext returns nil ctx when model has no encryptor
+func newContext(archivePath string, model config.ModelConfig) (Context, error) {
+	return newContextWithBase(newBase(archivePath, model))
+}
+
+func newContextWithBase(base Base) (Context, error) {
+	model := base.model
+	typ := model.EncryptWith.Type
+	if len(typ) == 0 {
+		return nil, nil
+	}
+
+	registryMu.RLock()
+	fn, ok := registry[typ]
+	registryMu.RUnlock()
+	if ok {
+		return fn(base)
+	}
+	if p, ok := plugin.Lookup(plugin.KindEncryptor, typ); ok {
+		return newExternal(base, pluginFactory(p))
+	}
+	return nil, fmt.Errorf("model: %s encrypt_with config `type: %s`, but is not implement", model.Name, typ)
+}
+
+// Validate check the encryptor type of model is supported
+func Validate(model config.ModelConfig) error {
+	_, err := newContext("", model)
+	return err
+}
+
+// Run encryptor, it stops when runCtx is done or the encrypt_with timeout
+// expired
+func Run(runCtx context.Context, archivePath string, model config.ModelConfig) (encryptPath string, err error) {
+	ctx, err := newContext(archivePath, model)
+	if err != nil || ctx == nil {
 		encryptPath = archivePath
 		return
 	}
 
-	slog.Info("Starting encryption", 
+	slog.Info("Starting encryption",
 		"component", "encryptor",
 		"model", model.Name,
 		"type", model.EncryptWith.Type,
 		"sourcePath", archivePath)
-	encryptPath, err = ctx.perform()
+	if helper.IsDryRun(runCtx) {
+		encryptPath = archivePath + ctx.ext()
+		slog.Info("Dry run, encryption skipped",
+			"component", "encryptor",
+			"model", model.Name,
+			"encryptPath", encryptPath)
+		return
+	}
+	runCtx, cancel := helper.WithTimeout(runCtx, "encrypt", model.EncryptWith.Timeout)
+	defer cancel()
+	encryptPath, err = ctx.perform(runCtx)
 	if err != nil {
 		return
 	}
-	slog.Info("Encryption completed", 
+	slog.Info("Encryption completed",
 		"component", "encryptor",
 		"model", model.Name,
 		"type", model.EncryptWith.Type,
@@ -57,3 +120,79 @@ func Run(archivePath string, model config.ModelConfig) (encryptPath string, err
 
 	return
 }
+
+// Decrypt an encrypted backup with the encrypt_with config of model,
+// the path is returned unchanged when model has no encryptor. A non empty
+// identity is used instead of the identity_file of age and gpg.
+func Decrypt(runCtx context.Context, encryptPath string, model config.ModelConfig, identity string) (archivePath string, err error) {
+	base := newBase(encryptPath, model)
+	base.identity = identity
+	ctx, err := newContextWithBase(base)
+	if err != nil || ctx == nil {
+		archivePath = encryptPath
+		return
+	}
+
+	slog.Info("Starting decryption",
+		"component", "encryptor",
+		"model", model.Name,
+		"type", model.EncryptWith.Type,
+		"sourcePath", encryptPath)
+	archivePath, err = ctx.decrypt(runCtx)
+	if err != nil {
+		return
+	}
+	slog.Info("Decryption completed",
+		"component", "encryptor",
+		"model", model.Name,
+		"type", model.EncryptWith.Type,
+		"archivePath", archivePath)
+
+	return
+}
+
+// Ext appended to the backup file name by the encryptor of model
+func Ext(model config.ModelConfig) (string, error) {
+	ctx, err := newContext("", model)
+	if err != nil || ctx == nil {
+		return "", err
+	}
+	return ctx.ext(), nil
+}
+
+// NewWriter encrypts everything written into w with the encryptor of model,
+// data passes through unchanged when model has no encryptor.
+func NewWriter(runCtx context.Context, model config.ModelConfig, w io.Writer) (io.WriteCloser, error) {
+	ctx, err := newContext("", model)
+	if err != nil {
+		return nil, err
+	}
+	if ctx == nil {
+		return nopWriteCloser{w}, nil
+	}
+	return ctx.newWriter(runCtx, w)
+}
+
+// cmdWriter feeds a running command through stdin
+type cmdWriter struct {
+	*io.PipeWriter
+	done chan error
+}
+
+// newCmdWriter runs fn in background with the reading end of a pipe
+func newCmdWriter(fn func(r io.Reader) error) *cmdWriter {
+	pr, pw := io.Pipe()
+	w := &cmdWriter{PipeWriter: pw, done: make(chan error, 1)}
+	go func() {
+		err := fn(pr)
+		pr.CloseWithError(err)
+		w.done <- err
+	}()
+	return w
+}
+
+// Close waits the command to exit
+func (w *cmdWriter) Close() error {
+	w.PipeWriter.Close()
+	return <-w.done
+}
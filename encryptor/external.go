@@ -0,0 +1,106 @@
+package encryptor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/holgerhuo/gobackup/config"
+)
+
+// Encryptor is an encryptor type added with Register, ctx is done when the
+// run is cancelled or the timeout of encrypt_with expired
+type Encryptor interface {
+	// Ext appended to the backup file name, example: .kms
+	Ext() string
+	// NewWriter encrypts everything written into w, closing it must not
+	// close w
+	NewWriter(ctx context.Context, w io.Writer) (io.WriteCloser, error)
+	// NewReader decrypts r
+	NewReader(ctx context.Context, r io.Reader) (io.Reader, error)
+}
+
+// Factory creates the Encryptor of model from the keys of encrypt_with
+type Factory func(model string, settings map[string]interface{}) (Encryptor, error)
+
+// newContextFunc creates the Context of an encryptor type
+type newContextFunc func(base Base) (Context, error)
+
+var (
+	registryMu sync.RWMutex
+	// registry of encryptor types, the built in types register from init
+	registry = map[string]newContextFunc{}
+)
+
+// register adds an encryptor type with its config schema
+func register(typ string, fn newContextFunc, schema config.Schema) {
+	config.RegisterSchema(config.SectionEncryptor, typ, schema)
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[typ] = fn
+}
+
+// Register adds an encryptor type, usable as `type: typ` in the config.
+// It panics when typ is taken, schema lists the keys the type accepts.
+func Register(typ string, factory Factory, schema config.Schema) {
+	if factory == nil {
+		panic("encryptor: Register factory is nil")
+	}
+	register(typ, func(base Base) (Context, error) {
+		return newExternal(base, factory)
+	}, schema)
+}
+
+// external runs an Encryptor added with Register or found as plugin
+type external struct {
+	Base
+	encryptor Encryptor
+}
+
+func newExternal(base Base, factory Factory) (Context, error) {
+	var settings map[string]interface{}
+	if base.viper != nil {
+		settings = base.viper.AllSettings()
+	}
+	if len(base.identity) > 0 {
+		if settings == nil {
+			settings = map[string]interface{}{}
+		}
+		settings["identity_file"] = base.identity
+	}
+	encryptor, err := factory(base.model.Name, settings)
+	if err != nil {
+		return nil, fmt.Errorf("model: %s encrypt_with: %s", base.model.Name, err)
+	}
+	return &external{Base: base, encryptor: encryptor}, nil
+}
+
+func (ctx *external) ext() string {
+	return ctx.encryptor.Ext()
+}
+
+func (ctx *external) newWriter(runCtx context.Context, w io.Writer) (io.WriteCloser, error) {
+	return ctx.encryptor.NewWriter(runCtx, w)
+}
+
+func (ctx *external) perform(runCtx context.Context) (encryptPath string, err error) {
+	encryptPath = ctx.archivePath + ctx.ext()
+	err = transformFile(runCtx, ctx.archivePath, encryptPath, func(w io.Writer) (io.WriteCloser, error) {
+		return ctx.newWriter(runCtx, w)
+	}, nil)
+	return
+}
+
+func (ctx *external) decrypt(runCtx context.Context) (archivePath string, err error) {
+	archivePath = strings.TrimSuffix(ctx.archivePath, ctx.ext())
+	if archivePath == ctx.archivePath {
+		archivePath = ctx.archivePath + ".dec"
+	}
+	err = transformFile(runCtx, ctx.archivePath, archivePath, nil, func(r io.Reader) (io.Reader, error) {
+		return ctx.encryptor.NewReader(runCtx, r)
+	})
+	return
+}
@@ -0,0 +1,220 @@
+package encryptor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/holgerhuo/gobackup/config"
+	"github.com/holgerhuo/gobackup/helper"
+)
+
+// GPG encryptor, OpenPGP messages to public keys or with a passphrase
+//
+// type: gpg
+// keyring: # public keyring file, armored or binary
+// public_key: # armored public key block
+// recipients: # key ids, fingerprints or emails picked from the keys, default all
+// passphrase: # symmetric encryption instead of public keys
+// identity_file: # secret key to decrypt, only needed to restore
+// identity_passphrase: # unlocks identity_file
+type GPG struct {
+	Base
+	keys               openpgp.EntityList
+	passphrase         string
+	identityPassphrase string
+}
+
+func init() {
+	register("gpg", func(base Base) (Context, error) {
+		return &GPG{Base: base}, nil
+	}, config.Schema{
+		Optional: []string{"keyring", "public_key", "recipients", "passphrase", "identity_file", "identity_passphrase"},
+	})
+}
+
+func (ctx *GPG) load() (err error) {
+	ctx.passphrase = ctx.viper.GetString("passphrase")
+	ctx.identityPassphrase = ctx.viper.GetString("identity_passphrase")
+
+	ctx.keys = nil
+	if file := ctx.viper.GetString("keyring"); len(file) > 0 {
+		keys, err := readGPGKeyFile(file)
+		if err != nil {
+			return fmt.Errorf("read keyring failed: %s", err)
+		}
+		ctx.keys = append(ctx.keys, keys...)
+	}
+	if key := ctx.viper.GetString("public_key"); len(key) > 0 {
+		keys, err := openpgp.ReadArmoredKeyRing(strings.NewReader(key))
+		if err != nil {
+			return fmt.Errorf("read public_key failed: %s", err)
+		}
+		ctx.keys = append(ctx.keys, keys...)
+	}
+	if recipients := ctx.viper.GetStringSlice("recipients"); len(recipients) > 0 {
+		if ctx.keys, err = selectGPGKeys(ctx.keys, recipients); err != nil {
+			return
+		}
+	}
+
+	if len(ctx.keys) > 0 && len(ctx.passphrase) > 0 {
+		return fmt.Errorf("use either public keys or passphrase, not both")
+	}
+	return nil
+}
+
+func readGPGKeyFile(path string) (openpgp.EntityList, error) {
+	data, err := os.ReadFile(helper.ExplandHome(path))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN")) {
+		return openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	}
+	return openpgp.ReadKeyRing(bytes.NewReader(data))
+}
+
+// selectGPGKeys keeps the keys matching a key id, fingerprint suffix or email
+func selectGPGKeys(keys openpgp.EntityList, recipients []string) (openpgp.EntityList, error) {
+	var selected openpgp.EntityList
+	for _, recipient := range recipients {
+		recipient = strings.TrimPrefix(strings.ToUpper(strings.ReplaceAll(recipient, " ", "")), "0X")
+		found := false
+		for _, key := range keys {
+			if gpgKeyMatches(key, recipient) {
+				selected = append(selected, key)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("gpg recipient %s not found in keys", recipient)
+		}
+	}
+	return selected, nil
+}
+
+func gpgKeyMatches(key *openpgp.Entity, recipient string) bool {
+	if strings.HasSuffix(strings.ToUpper(fmt.Sprintf("%X", key.PrimaryKey.Fingerprint)), recipient) {
+		return true
+	}
+	for _, identity := range key.Identities {
+		if identity.UserId != nil && strings.EqualFold(identity.UserId.Email, recipient) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ctx *GPG) ext() string {
+	return ".gpg"
+}
+
+func (ctx *GPG) perform(runCtx context.Context) (encryptPath string, err error) {
+	if err = ctx.load(); err != nil {
+		return
+	}
+
+	encryptPath = ctx.archivePath + ctx.ext()
+	err = transformFile(runCtx, ctx.archivePath, encryptPath, ctx.encrypt, nil)
+	return
+}
+
+func (ctx *GPG) newWriter(runCtx context.Context, w io.Writer) (io.WriteCloser, error) {
+	if err := ctx.load(); err != nil {
+		return nil, err
+	}
+	return ctx.encrypt(w)
+}
+
+func (ctx *GPG) encrypt(w io.Writer) (io.WriteCloser, error) {
+	hints := &openpgp.FileHints{IsBinary: true}
+	if len(ctx.passphrase) > 0 {
+		return openpgp.SymmetricallyEncrypt(w, []byte(ctx.passphrase), hints, nil)
+	}
+	if len(ctx.keys) == 0 {
+		return nil, fmt.Errorf("keyring, public_key or passphrase option is required")
+	}
+	return openpgp.Encrypt(w, ctx.keys, nil, hints, nil)
+}
+
+func (ctx *GPG) decrypt(runCtx context.Context) (archivePath string, err error) {
+	if err = ctx.load(); err != nil {
+		return
+	}
+
+	var keyring openpgp.EntityList
+	if len(ctx.passphrase) == 0 {
+		if keyring, err = ctx.identities(); err != nil {
+			return
+		}
+	}
+
+	archivePath = strings.TrimSuffix(ctx.archivePath, ctx.ext())
+	if archivePath == ctx.archivePath {
+		archivePath = ctx.archivePath + ".dec"
+	}
+	err = transformFile(runCtx, ctx.archivePath, archivePath, nil, func(r io.Reader) (io.Reader, error) {
+		md, err := openpgp.ReadMessage(r, keyring, ctx.prompt(), nil)
+		if err != nil {
+			return nil, err
+		}
+		return md.UnverifiedBody, nil
+	})
+	return
+}
+
+// identities reads the secret keys of identity_file, unlocked with identity_passphrase
+func (ctx *GPG) identities() (openpgp.EntityList, error) {
+	identityFile := ctx.identityFile()
+	if len(identityFile) == 0 {
+		return nil, fmt.Errorf("identity_file option is required to decrypt")
+	}
+	keys, err := readGPGKeyFile(identityFile)
+	if err != nil {
+		return nil, fmt.Errorf("read identity_file failed: %s", err)
+	}
+	for _, key := range keys {
+		if key.PrivateKey == nil {
+			continue
+		}
+		if key.PrivateKey.Encrypted || hasEncryptedSubkey(key) {
+			if len(ctx.identityPassphrase) == 0 {
+				return nil, fmt.Errorf("identity_passphrase option is required to unlock identity_file")
+			}
+			if err = key.DecryptPrivateKeys([]byte(ctx.identityPassphrase)); err != nil {
+				return nil, fmt.Errorf("unlock identity_file failed: %s", err)
+			}
+		}
+	}
+	return keys, nil
+}
+
+func hasEncryptedSubkey(key *openpgp.Entity) bool {
+	for _, subkey := range key.Subkeys {
+		if subkey.PrivateKey != nil && subkey.PrivateKey.Encrypted {
+			return true
+		}
+	}
+	return false
+}
+
+// prompt hands out the passphrase once, the keys are unlocked beforehand
+func (ctx *GPG) prompt() openpgp.PromptFunction {
+	asked := false
+	return func(keys []openpgp.Key, symmetric bool) ([]byte, error) {
+		if !symmetric || len(ctx.passphrase) == 0 {
+			return nil, errors.New("no secret key in identity_file can decrypt the backup")
+		}
+		if asked {
+			return nil, errors.New("wrong gpg passphrase")
+		}
+		asked = true
+		return []byte(ctx.passphrase), nil
+	}
+}
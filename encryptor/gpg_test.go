@@ -0,0 +1,156 @@
+package encryptor
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/holgerhuo/gobackup/config"
+)
+
+// newGPGKey returns an armored public key and the armored secret key locked
+// with passphrase
+func newGPGKey(t *testing.T, passphrase string) (public, secret string) {
+	t.Helper()
+	entity, err := openpgp.NewEntity("Backup", "", "backup@example.com", &packet.Config{Algorithm: packet.PubKeyAlgoEdDSA})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	armorKey := func(blockType string, serialize func(w *bytes.Buffer) error) string {
+		buf := &bytes.Buffer{}
+		w, err := armor.Encode(buf, blockType, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw := &bytes.Buffer{}
+		if err = serialize(raw); err != nil {
+			t.Fatal(err)
+		}
+		w.Write(raw.Bytes())
+		if err = w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+
+	public = armorKey(openpgp.PublicKeyType, func(w *bytes.Buffer) error {
+		return entity.Serialize(w)
+	})
+	if err = entity.EncryptPrivateKeys([]byte(passphrase), nil); err != nil {
+		t.Fatal(err)
+	}
+	secret = armorKey(openpgp.PrivateKeyType, func(w *bytes.Buffer) error {
+		return entity.SerializePrivateWithoutSigning(w, nil)
+	})
+	return
+}
+
+// gpgRoundTrip encrypts plain through newWriter, then decrypts the file
+func gpgRoundTrip(t *testing.T, settings map[string]interface{}, plain string) (string, error) {
+	t.Helper()
+	settings["type"] = "gpg"
+	model, err := config.NewModel("app", map[string]interface{}{
+		"encrypt_with": settings,
+		"store_with":   map[string]interface{}{"type": "local", "path": t.TempDir()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, err := newContext("", model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	w, err := ctx.newWriter(context.Background(), buf)
+	if err != nil {
+		return "", err
+	}
+	w.Write([]byte(plain))
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(buf.Bytes(), []byte(plain)) {
+		t.Fatal("encrypted backup contains the plain text")
+	}
+
+	encryptPath := filepath.Join(t.TempDir(), "backup.tar.gpg")
+	if err = os.WriteFile(encryptPath, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ctx, err = newContext(encryptPath, model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archivePath, err := ctx.decrypt(context.Background())
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data), nil
+}
+
+func TestGPGPublicKey(t *testing.T) {
+	public, secret := newGPGKey(t, "unlock me")
+	identityPath := filepath.Join(t.TempDir(), "secret.asc")
+	if err := os.WriteFile(identityPath, []byte(secret), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := gpgRoundTrip(t, map[string]interface{}{
+		"public_key":          public,
+		"recipients":          []interface{}{"backup@example.com"},
+		"identity_file":       identityPath,
+		"identity_passphrase": "unlock me",
+	}, "dump")
+	if err != nil || got != "dump" {
+		t.Errorf("round trip = %q, %v, want dump", got, err)
+	}
+
+	_, err = gpgRoundTrip(t, map[string]interface{}{
+		"public_key":          public,
+		"identity_file":       identityPath,
+		"identity_passphrase": "wrong",
+	}, "dump")
+	if err == nil || !strings.Contains(err.Error(), "unlock identity_file") {
+		t.Errorf("wrong identity_passphrase error = %v", err)
+	}
+
+	_, err = gpgRoundTrip(t, map[string]interface{}{
+		"public_key":    public,
+		"identity_file": identityPath,
+	}, "dump")
+	if err == nil || !strings.Contains(err.Error(), "identity_passphrase") {
+		t.Errorf("missing identity_passphrase error = %v", err)
+	}
+}
+
+func TestGPGPassphrase(t *testing.T) {
+	got, err := gpgRoundTrip(t, map[string]interface{}{
+		"passphrase": "correct horse battery staple",
+	}, "dump")
+	if err != nil || got != "dump" {
+		t.Errorf("round trip = %q, %v, want dump", got, err)
+	}
+}
+
+func TestGPGPublicKeyAndPassphrase(t *testing.T) {
+	public, _ := newGPGKey(t, "unlock me")
+	_, err := gpgRoundTrip(t, map[string]interface{}{
+		"public_key": public,
+		"passphrase": "correct horse battery staple",
+	}, "dump")
+	if err == nil || !strings.Contains(err.Error(), "not both") {
+		t.Errorf("newWriter() error = %v, want public keys and passphrase rejected", err)
+	}
+}
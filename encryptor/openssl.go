@@ -1,9 +1,14 @@
 package encryptor
 
 import (
+	"context"
 	"fmt"
-	"github.com/holgerhuo/gobackup/helper"
+	"io"
+	"strings"
 	"time"
+
+	"github.com/holgerhuo/gobackup/config"
+	"github.com/holgerhuo/gobackup/helper"
 )
 
 // OpenSSL encryptor for use openssl aes-256-cbc
@@ -20,11 +25,20 @@ type OpenSSL struct {
 	password string
 }
 
-func (ctx *OpenSSL) perform() (encryptPath string, err error) {
+func init() {
+	register("openssl", func(base Base) (Context, error) {
+		return &OpenSSL{Base: base}, nil
+	}, config.Schema{
+		Required: []string{"password"},
+		Optional: []string{"salt", "base64", "iter", "pbkdf2"},
+	})
+}
+
+func (ctx *OpenSSL) load() error {
 	sslViper := ctx.viper
 	sslViper.SetDefault("salt", true)
 	sslViper.SetDefault("base64", false)
-    sslViper.SetDefault("iter", 100000)
+	sslViper.SetDefault("iter", 100000)
 	sslViper.SetDefault("pbkdf2", true)
 
 	ctx.salt = sslViper.GetBool("salt")
@@ -34,23 +48,68 @@ func (ctx *OpenSSL) perform() (encryptPath string, err error) {
 	ctx.password = sslViper.GetString("password")
 
 	if len(ctx.password) == 0 {
-		err = fmt.Errorf("password option is required")
+		return fmt.Errorf("password option is required")
+	}
+	return nil
+}
+
+func (ctx *OpenSSL) perform(runCtx context.Context) (encryptPath string, err error) {
+	if err = ctx.load(); err != nil {
+		return
+	}
+
+	encryptPath = ctx.archivePath + ctx.ext()
+	err = ctx.run(runCtx, false, ctx.archivePath, encryptPath)
+	return
+}
+
+func (ctx *OpenSSL) ext() string {
+	return ".enc"
+}
+
+func (ctx *OpenSSL) newWriter(runCtx context.Context, w io.Writer) (io.WriteCloser, error) {
+	if err := ctx.load(); err != nil {
+		return nil, err
+	}
+
+	envVarName, envVar := ctx.passwordEnv()
+	opts := ctx.options(envVarName)
+	return newCmdWriter(func(r io.Reader) error {
+		return helper.ExecStream(runCtx, "openssl", []string{envVar}, r, w, opts...)
+	}), nil
+}
+
+func (ctx *OpenSSL) decrypt(runCtx context.Context) (archivePath string, err error) {
+	if err = ctx.load(); err != nil {
 		return
 	}
 
-	encryptPath = ctx.archivePath + ".enc"
+	archivePath = strings.TrimSuffix(ctx.archivePath, ".enc")
+	if archivePath == ctx.archivePath {
+		archivePath = ctx.archivePath + ".dec"
+	}
+	err = ctx.run(runCtx, true, ctx.archivePath, archivePath)
+	return
+}
+
+// passwordEnv passes the password in an environment variable with a unique name
+func (ctx *OpenSSL) passwordEnv() (envVarName, envVar string) {
+	envVarName = fmt.Sprintf("GOBACKUP_OPENSSL_PASSWORD_%d", time.Now().UnixNano())
+	envVar = fmt.Sprintf("%s=%s", envVarName, ctx.password)
+	return
+}
+
+func (ctx *OpenSSL) run(runCtx context.Context, decrypt bool, inPath, outPath string) (err error) {
+	envVarName, envVar := ctx.passwordEnv()
 
-	// Create a unique environment variable name
-	envVarName := fmt.Sprintf("GOBACKUP_OPENSSL_PASSWORD_%d", time.Now().UnixNano())
-	
-	// Set up the environment variable with the password
-	envVar := fmt.Sprintf("%s=%s", envVarName, ctx.password)
-	
 	opts := ctx.options(envVarName)
-	opts = append(opts, "-in", ctx.archivePath, "-out", encryptPath)
-	
+	if decrypt {
+		opts = append(opts, "-d")
+	}
+	opts = append(opts, "-in", inPath, "-out", outPath)
+
 	// Execute with the password in an environment variable
-	_, err = helper.ExecWithCustomEnv("openssl", []string{envVar}, opts...)
+	_, err = helper.ExecWithCustomEnv(runCtx, "openssl", []string{envVar}, opts...)
 	return
 }
 
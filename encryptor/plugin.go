@@ -0,0 +1,47 @@
+package encryptor
+
+import (
+	"context"
+	"io"
+
+	"github.com/holgerhuo/gobackup/plugin"
+)
+
+// pluginEncryptor runs an encryptor type of an exec plugin, the plugin
+// describes the ext
+type pluginEncryptor struct {
+	plugin   *plugin.Plugin
+	model    string
+	settings map[string]interface{}
+	ext      string
+}
+
+func pluginFactory(p *plugin.Plugin) Factory {
+	return func(model string, settings map[string]interface{}) (Encryptor, error) {
+		desc, err := p.Describe()
+		if err != nil {
+			return nil, err
+		}
+		return &pluginEncryptor{plugin: p, model: model, settings: settings, ext: desc.Ext}, nil
+	}
+}
+
+func (e *pluginEncryptor) request(method string) plugin.Request {
+	req := e.plugin.NewRequest(method)
+	req.Model = e.model
+	req.Settings = e.settings
+	return req
+}
+
+func (e *pluginEncryptor) Ext() string {
+	return e.ext
+}
+
+func (e *pluginEncryptor) NewWriter(ctx context.Context, w io.Writer) (io.WriteCloser, error) {
+	return e.plugin.NewWriter(ctx, e.request("encrypt"), w), nil
+}
+
+// NewReader fails reading when the plugin fails, EOF means it succeeded
+func (e *pluginEncryptor) NewReader(ctx context.Context, r io.Reader) (io.Reader, error) {
+	return e.plugin.NewReader(ctx, e.request("decrypt"), r), nil
+}
@@ -0,0 +1,164 @@
+// Package gobackup runs backup models from Go programs, and adds custom
+// database, storage, compressor and encryptor types next to the built in
+// ones.
+//
+//	model, err := gobackup.NewModelConfig("app", gobackup.Settings{
+//		"databases": gobackup.Settings{
+//			"app": gobackup.Settings{"type": "postgresql", "database": "app"},
+//		},
+//		"store_with": gobackup.Settings{"type": "local", "path": "/var/backups"},
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	return gobackup.Run(ctx, model)
+package gobackup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/holgerhuo/gobackup/compressor"
+	"github.com/holgerhuo/gobackup/config"
+	"github.com/holgerhuo/gobackup/database"
+	"github.com/holgerhuo/gobackup/encryptor"
+	"github.com/holgerhuo/gobackup/helper"
+	"github.com/holgerhuo/gobackup/model"
+	"github.com/holgerhuo/gobackup/storage"
+)
+
+type (
+	// Config of a loaded config file
+	Config = config.Config
+	// ModelConfig of one backup model
+	ModelConfig = config.ModelConfig
+	// Settings with the keys of gobackup.yml
+	Settings = map[string]interface{}
+	// Schema of the keys a registered type accepts
+	Schema = config.Schema
+	// StageError tells which stage of a backup failed
+	StageError = model.StageError
+	// RestoreOptions for Restore
+	RestoreOptions = model.RestoreOptions
+	// Listing of the backups in one storage
+	Listing = storage.Listing
+	// FileItem a file kept in storage
+	FileItem = storage.FileItem
+
+	// Database type added with RegisterDatabase
+	Database = database.Database
+	// DatabaseFactory creates a Database from its settings
+	DatabaseFactory = database.Factory
+	// EntryFunc opens a writer for a dump file
+	EntryFunc = database.EntryFunc
+	// DatabaseRestoreOptions where restored databases go
+	DatabaseRestoreOptions = database.RestoreOptions
+
+	// Storage type added with RegisterStorage
+	Storage = storage.Storage
+	// StorageFactory creates a Storage from its settings
+	StorageFactory = storage.Factory
+
+	// Compressor type added with RegisterCompressor
+	Compressor = compressor.Compressor
+	// CompressorFactory creates a Compressor from its settings
+	CompressorFactory = compressor.Factory
+
+	// Encryptor type added with RegisterEncryptor
+	Encryptor = encryptor.Encryptor
+	// EncryptorFactory creates an Encryptor from its settings
+	EncryptorFactory = encryptor.Factory
+)
+
+// LoadConfig reads a config file like the gobackup command does
+func LoadConfig(configFile string) (*Config, error) {
+	return config.Load(configFile)
+}
+
+// NewModelConfig builds a model from settings with the keys of a model in
+// gobackup.yml, secret references like ${ENV} are resolved.
+func NewModelConfig(name string, settings Settings) (ModelConfig, error) {
+	return config.NewModel(name, settings)
+}
+
+// Validate checks every type used by model is supported
+func Validate(modelConfig ModelConfig) error {
+	m := model.Model{Config: modelConfig}
+	return m.Validate()
+}
+
+// WithDryRun marks ctx so Run only logs the commands and destinations of
+// the model, nothing is dumped or uploaded. Other runs aren't affected.
+func WithDryRun(ctx context.Context) context.Context {
+	return helper.WithDryRun(ctx)
+}
+
+// Run performs one backup of model, the error is a *StageError when a
+// stage failed.
+func Run(ctx context.Context, modelConfig ModelConfig) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := Validate(modelConfig); err != nil {
+		return err
+	}
+
+	m := model.Model{Config: modelConfig}
+	return m.Perform(ctx)
+}
+
+// Restore fetches a backup of model, decrypts and extracts it, then
+// restores its databases.
+func Restore(ctx context.Context, modelConfig ModelConfig, opts RestoreOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := Validate(modelConfig); err != nil {
+		return err
+	}
+
+	m := model.Model{Config: modelConfig}
+	return m.Restore(ctx, opts)
+}
+
+// List the backups of model in every storage, the error joins the ones
+// of the storages that could not be listed.
+func List(ctx context.Context, modelConfig ModelConfig) ([]Listing, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	listings := storage.List(ctx, modelConfig)
+	var errs []error
+	for _, listing := range listings {
+		if listing.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", listing.Storage, listing.Err))
+		}
+	}
+	return listings, errors.Join(errs...)
+}
+
+// RegisterDatabase adds a database type, it panics when typ is taken.
+// Call it from init, before any config is used.
+func RegisterDatabase(typ string, factory DatabaseFactory, schema Schema) {
+	database.Register(typ, factory, schema)
+}
+
+// RegisterStorage adds a storage type, it panics when typ is taken.
+// Call it from init, before any config is used.
+func RegisterStorage(typ string, factory StorageFactory, schema Schema) {
+	storage.Register(typ, factory, schema)
+}
+
+// RegisterCompressor adds a compressor type, it panics when typ is taken.
+// Call it from init, before any config is used.
+func RegisterCompressor(typ string, factory CompressorFactory, schema Schema) {
+	compressor.Register(typ, factory, schema)
+}
+
+// RegisterEncryptor adds an encryptor type, it panics when typ is taken.
+// Call it from init, before any config is used.
+func RegisterEncryptor(typ string, factory EncryptorFactory, schema Schema) {
+	encryptor.Register(typ, factory, schema)
+}
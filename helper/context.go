@@ -0,0 +1,73 @@
+package helper
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// WithTimeout limits ctx to timeout, no limit when timeout is 0. Once it
+// expires context.Cause tells which stage ran out of time.
+func WithTimeout(ctx context.Context, stage string, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, timeout, fmt.Errorf("%s timeout %s exceeded", stage, timeout))
+}
+
+type dryRunKey struct{}
+
+// WithDryRun marks ctx as a dry run, commands run with it are only logged
+// and nothing is written or uploaded
+func WithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+// IsDryRun tells whether ctx was marked by WithDryRun
+func IsDryRun(ctx context.Context) bool {
+	dryRun, _ := ctx.Value(dryRunKey{}).(bool)
+	return dryRun
+}
+
+// ContextErr is the cause of ctx being done, nil while it's not
+func ContextErr(ctx context.Context) error {
+	if ctx.Err() == nil {
+		return nil
+	}
+	return context.Cause(ctx)
+}
+
+// NewContextReader fails reading once ctx is done
+func NewContextReader(ctx context.Context, r io.Reader) io.Reader {
+	return &contextReader{ctx: ctx, r: r}
+}
+
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := ContextErr(r.ctx); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// NewContextWriter fails writing once ctx is done
+func NewContextWriter(ctx context.Context, w io.Writer) io.Writer {
+	return &contextWriter{ctx: ctx, w: w}
+}
+
+type contextWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (w *contextWriter) Write(p []byte) (int, error) {
+	if err := ContextErr(w.ctx); err != nil {
+		return 0, err
+	}
+	return w.w.Write(p)
+}
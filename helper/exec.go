@@ -2,8 +2,10 @@ package helper
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/exec"
@@ -15,8 +17,40 @@ var (
 	spaceRegexp = regexp.MustCompile("[\\s]+")
 )
 
+// skipDryRun logs the command with secrets redacted, returns true when ctx
+// is a dry run
+func skipDryRun(ctx context.Context, command string, args []string) bool {
+	if !IsDryRun(ctx) {
+		return false
+	}
+	slog.Info("Dry run, command skipped",
+		"component", "exec",
+		"command", Redact(command),
+		"args", Redact(strings.Join(args, " ")))
+	return true
+}
+
+// newCommand runs in its own process group, killed with all its children
+// when ctx is done
+func newCommand(ctx context.Context, fullCommand string, args []string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, fullCommand, args...)
+	setProcessGroup(cmd)
+	return cmd
+}
+
+// commandError is stderr of a failed command, or why it was killed
+func commandError(ctx context.Context, command string, err error, stdErr string) error {
+	if ctxErr := ContextErr(ctx); ctxErr != nil {
+		return fmt.Errorf("%s killed: %w", command, ctxErr)
+	}
+	if len(stdErr) > 0 {
+		return errors.New(stdErr)
+	}
+	return err
+}
+
 // Exec cli commands
-func Exec(command string, args ...string) (output string, err error) {
+func Exec(ctx context.Context, command string, args ...string) (output string, err error) {
 	commands := spaceRegexp.Split(command, -1)
 	command = commands[0]
 	commandArgs := []string{}
@@ -27,12 +61,16 @@ func Exec(command string, args ...string) (output string, err error) {
 		commandArgs = append(commandArgs, args...)
 	}
 
+	if skipDryRun(ctx, command, commandArgs) {
+		return "", nil
+	}
+
 	fullCommand, err := exec.LookPath(command)
 	if err != nil {
 		return "", fmt.Errorf("%s cannot be found", command)
 	}
 
-	cmd := exec.Command(fullCommand, commandArgs...)
+	cmd := newCommand(ctx, fullCommand, commandArgs)
 	cmd.Env = os.Environ()
 
 	var stdErr bytes.Buffer
@@ -42,16 +80,16 @@ func Exec(command string, args ...string) (output string, err error) {
 	slog.Debug("Executing command",
 		"component", "exec",
 		"command", fullCommand,
-		"args", strings.Join(commandArgs, " "))
+		"args", Redact(strings.Join(commandArgs, " ")))
 
 	out, err := cmd.Output()
 	if err != nil {
 		slog.Debug("Command execution failed",
 			"component", "exec",
 			"command", fullCommand,
-			"args", strings.Join(commandArgs, " "),
+			"args", Redact(strings.Join(commandArgs, " ")),
 			"error", stdErr.String())
-		err = errors.New(stdErr.String())
+		err = commandError(ctx, command, err, stdErr.String())
 		return
 	}
 
@@ -60,7 +98,7 @@ func Exec(command string, args ...string) (output string, err error) {
 }
 
 // ExecWithCustomEnv executes a command with additional environment variables
-func ExecWithCustomEnv(command string, envVars []string, args ...string) (output string, err error) {
+func ExecWithCustomEnv(ctx context.Context, command string, envVars []string, args ...string) (output string, err error) {
 	commands := spaceRegexp.Split(command, -1)
 	command = commands[0]
 	commandArgs := []string{}
@@ -71,31 +109,35 @@ func ExecWithCustomEnv(command string, envVars []string, args ...string) (output
 		commandArgs = append(commandArgs, args...)
 	}
 
+	if skipDryRun(ctx, command, commandArgs) {
+		return "", nil
+	}
+
 	fullCommand, err := exec.LookPath(command)
 	if err != nil {
 		return "", fmt.Errorf("%s cannot be found", command)
 	}
 
-	cmd := exec.Command(fullCommand, commandArgs...)
+	cmd := newCommand(ctx, fullCommand, commandArgs)
 	cmd.Env = append(os.Environ(), envVars...)
 
 	var stdErr bytes.Buffer
 	cmd.Stderr = &stdErr
 
 	// Log command at debug level
-	slog.Debug("Executing command with custom env", 
+	slog.Debug("Executing command with custom env",
 		"component", "exec",
 		"command", fullCommand,
-		"args", strings.Join(commandArgs, " "))
+		"args", Redact(strings.Join(commandArgs, " ")))
 
 	out, err := cmd.Output()
 	if err != nil {
-		slog.Debug("Command execution failed", 
+		slog.Debug("Command execution failed",
 			"component", "exec",
 			"command", fullCommand,
-			"args", strings.Join(commandArgs, " "),
+			"args", Redact(strings.Join(commandArgs, " ")),
 			"error", stdErr.String())
-		err = errors.New(stdErr.String())
+		err = commandError(ctx, command, err, stdErr.String())
 		return
 	}
 
@@ -103,7 +145,14 @@ func ExecWithCustomEnv(command string, envVars []string, args ...string) (output
 	return
 }
 
-func ExecWithStdio(command string, stdout bool, args ...string) (output string, err error) {
+// ExecScript runs script with sh -c, so variables, quotes and pipes in it
+// are handled by the shell. Its stdout goes to os.Stdout.
+func ExecScript(ctx context.Context, script string) error {
+	_, err := ExecWithStdio(ctx, "sh", true, "-c", script)
+	return err
+}
+
+func ExecWithStdio(ctx context.Context, command string, stdout bool, args ...string) (output string, err error) {
 	commands := spaceRegexp.Split(command, -1)
 	command = commands[0]
 	commandArgs := []string{}
@@ -114,12 +163,16 @@ func ExecWithStdio(command string, stdout bool, args ...string) (output string,
 		commandArgs = append(commandArgs, args...)
 	}
 
+	if skipDryRun(ctx, command, commandArgs) {
+		return "", nil
+	}
+
 	fullCommand, err := exec.LookPath(command)
 	if err != nil {
 		return "", fmt.Errorf("%s cannot be found", command)
 	}
 
-	cmd := exec.Command(fullCommand, commandArgs...)
+	cmd := newCommand(ctx, fullCommand, commandArgs)
 	cmd.Env = os.Environ()
 
 	var stdErr bytes.Buffer
@@ -137,11 +190,61 @@ func ExecWithStdio(command string, stdout bool, args ...string) (output string,
 		slog.Debug("Command execution failed",
 			"component", "exec",
 			"command", fullCommand,
-			"args", strings.Join(commandArgs, " "),
+			"args", Redact(strings.Join(commandArgs, " ")),
 			"error", stdErr.String())
-		err = errors.New(stdErr.String())
+		err = commandError(ctx, command, err, stdErr.String())
 	}
 	output = strings.Trim(stdOut.String(), "\n")
 
 	return
 }
+
+// ExecStream runs command with stdin and stdout connected to the given
+// reader and writer, either may be nil. In dry run stdin is drained.
+func ExecStream(ctx context.Context, command string, envVars []string, stdin io.Reader, stdout io.Writer, args ...string) (err error) {
+	commands := spaceRegexp.Split(command, -1)
+	command = commands[0]
+	commandArgs := []string{}
+	if len(commands) > 1 {
+		commandArgs = commands[1:]
+	}
+	if len(args) > 0 {
+		commandArgs = append(commandArgs, args...)
+	}
+
+	if skipDryRun(ctx, command, commandArgs) {
+		if stdin != nil {
+			_, err = io.Copy(io.Discard, stdin)
+		}
+		return err
+	}
+
+	fullCommand, err := exec.LookPath(command)
+	if err != nil {
+		return fmt.Errorf("%s cannot be found", command)
+	}
+
+	cmd := newCommand(ctx, fullCommand, commandArgs)
+	cmd.Env = append(os.Environ(), envVars...)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+
+	var stdErr bytes.Buffer
+	cmd.Stderr = &stdErr
+
+	slog.Debug("Executing command with stream",
+		"component", "exec",
+		"command", fullCommand,
+		"args", Redact(strings.Join(commandArgs, " ")))
+
+	err = cmd.Run()
+	if err != nil {
+		slog.Debug("Command execution failed",
+			"component", "exec",
+			"command", fullCommand,
+			"args", Redact(strings.Join(commandArgs, " ")),
+			"error", stdErr.String())
+		return commandError(ctx, command, err, stdErr.String())
+	}
+	return nil
+}
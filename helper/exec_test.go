@@ -0,0 +1,30 @@
+package helper
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExecScript(t *testing.T) {
+	t.Setenv("GOBACKUP_TEST_SCRIPT", "hello world")
+	out := filepath.Join(t.TempDir(), "out")
+
+	script := `echo "${GOBACKUP_TEST_SCRIPT}" | tr a-z A-Z > ` + out
+	if err := ExecScript(context.Background(), script); err != nil {
+		t.Fatalf("ExecScript() error = %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(data)); got != "HELLO WORLD" {
+		t.Errorf("script output = %q, want HELLO WORLD", got)
+	}
+
+	if err := ExecScript(context.Background(), "exit 3"); err == nil {
+		t.Error("ExecScript(exit 3) error = nil")
+	}
+}
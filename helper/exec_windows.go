@@ -0,0 +1,9 @@
+//go:build windows
+
+package helper
+
+import "os/exec"
+
+// setProcessGroup keeps the default of killing the process, Windows has no
+// process groups to signal.
+func setProcessGroup(cmd *exec.Cmd) {}
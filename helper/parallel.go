@@ -0,0 +1,100 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+)
+
+// Parallel runs fn for every index below n with at most limit running at
+// once, the returned errors are indexed like the jobs. With stopOnError no
+// job starts after one failed, the running ones are waited for. A job that
+// panics fails with the panic as its error.
+func Parallel(n, limit int, stopOnError bool, fn func(i int) error) []error {
+	if limit < 1 {
+		limit = 1
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed bool
+		errs   = make([]error, n)
+		sem    = make(chan struct{}, limit)
+	)
+	for i := 0; i < n; i++ {
+		sem <- struct{}{}
+
+		mu.Lock()
+		stop := failed && stopOnError
+		mu.Unlock()
+		if stop {
+			<-sem
+			break
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := runJob(fn, i); err != nil {
+				mu.Lock()
+				errs[i] = err
+				failed = true
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
+
+// errSiblingFailed cancels the jobs of ParallelCancel once one failed
+var errSiblingFailed = errors.New("stopped, another job failed")
+
+// ParallelCancel is Parallel stopping on error, the ctx of the running jobs
+// is also cancelled on the first error so they don't run to the end. Errors
+// of the jobs stopped that way are left out, the first error is reported.
+func ParallelCancel(ctx context.Context, n, limit int, fn func(ctx context.Context, i int) error) []error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	return Parallel(n, limit, true, func(i int) error {
+		err := Recover(func() error {
+			return fn(ctx, i)
+		})
+		if err == nil || errors.Is(context.Cause(ctx), errSiblingFailed) {
+			return nil
+		}
+		cancel(errSiblingFailed)
+		return err
+	})
+}
+
+// runJob calls fn, a panic is returned as error so one job can't crash the
+// process
+func runJob(fn func(i int) error, i int) error {
+	return Recover(func() error {
+		return fn(i)
+	})
+}
+
+// Recover calls fn and returns a panic of fn as error, for goroutines
+// running code of registered types or plugins
+func Recover(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("Recovered from panic",
+				"component", "helper",
+				"error", r,
+				"stack", string(debug.Stack()))
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn()
+}
@@ -0,0 +1,172 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParallel(t *testing.T) {
+	errFailed := errors.New("failed")
+
+	cases := []struct {
+		name        string
+		n, limit    int
+		stopOnError bool
+		fail        map[int]bool
+		wantRun     []bool
+	}{
+		{
+			name:    "all succeed",
+			n:       4,
+			limit:   2,
+			wantRun: []bool{true, true, true, true},
+		},
+		{
+			name:    "failure doesn't stop",
+			n:       4,
+			limit:   1,
+			fail:    map[int]bool{1: true},
+			wantRun: []bool{true, true, true, true},
+		},
+		{
+			name:        "stop on error",
+			n:           4,
+			limit:       1,
+			stopOnError: true,
+			fail:        map[int]bool{1: true},
+			wantRun:     []bool{true, true, false, false},
+		},
+		{
+			name:        "zero limit runs one at a time",
+			n:           3,
+			limit:       0,
+			stopOnError: true,
+			fail:        map[int]bool{0: true},
+			wantRun:     []bool{true, false, false},
+		},
+		{
+			name:    "no jobs",
+			n:       0,
+			limit:   2,
+			wantRun: []bool{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ran := make([]bool, c.n)
+			errs := Parallel(c.n, c.limit, c.stopOnError, func(i int) error {
+				ran[i] = true
+				if c.fail[i] {
+					return errFailed
+				}
+				return nil
+			})
+			if len(errs) != c.n {
+				t.Fatalf("len(errs) = %d, want %d", len(errs), c.n)
+			}
+			for i := range c.wantRun {
+				if ran[i] != c.wantRun[i] {
+					t.Errorf("job %d ran = %v, want %v", i, ran[i], c.wantRun[i])
+				}
+				if wantErr := c.fail[i] && c.wantRun[i]; (errs[i] != nil) != wantErr {
+					t.Errorf("errs[%d] = %v, want error %v", i, errs[i], wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestParallelLimit(t *testing.T) {
+	var running, peak atomic.Int32
+	Parallel(10, 3, false, func(i int) error {
+		now := running.Add(1)
+		for {
+			old := peak.Load()
+			if now <= old || peak.CompareAndSwap(old, now) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		running.Add(-1)
+		return nil
+	})
+	if got := peak.Load(); got != 3 {
+		t.Errorf("peak running = %d, want 3", got)
+	}
+}
+
+func TestParallelStopWaitsForRunning(t *testing.T) {
+	var finished atomic.Bool
+	errs := Parallel(3, 2, true, func(i int) error {
+		switch i {
+		case 0:
+			return errors.New("failed")
+		case 1:
+			time.Sleep(20 * time.Millisecond)
+			finished.Store(true)
+		}
+		return nil
+	})
+	if !finished.Load() {
+		t.Errorf("Parallel() returned before a running job finished")
+	}
+	if errs[0] == nil {
+		t.Errorf("errs[0] = nil, want error")
+	}
+}
+
+func TestParallelPanic(t *testing.T) {
+	errs := Parallel(3, 3, false, func(i int) error {
+		if i == 1 {
+			panic("boom")
+		}
+		return nil
+	})
+	if errs[1] == nil || !strings.Contains(errs[1].Error(), "panic: boom") {
+		t.Errorf("errs[1] = %v, want panic error", errs[1])
+	}
+	if errs[0] != nil || errs[2] != nil {
+		t.Errorf("errs = %v, want only job 1 failed", errs)
+	}
+}
+
+func TestParallelCancel(t *testing.T) {
+	start := time.Now()
+	errs := ParallelCancel(context.Background(), 3, 3, func(ctx context.Context, i int) error {
+		if i == 0 {
+			time.Sleep(10 * time.Millisecond)
+			return errors.New("failed")
+		}
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case <-time.After(5 * time.Second):
+			return nil
+		}
+	})
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("ParallelCancel() took %s, running jobs weren't cancelled", elapsed)
+	}
+	if errs[0] == nil || errs[0].Error() != "failed" {
+		t.Errorf("errs[0] = %v, want failed", errs[0])
+	}
+	if errs[1] != nil || errs[2] != nil {
+		t.Errorf("errs = %v, want only the first error", errs)
+	}
+}
+
+func TestParallelCancelParent(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	errs := ParallelCancel(ctx, 2, 2, func(ctx context.Context, i int) error {
+		return ctx.Err()
+	})
+	if !errors.Is(errs[0], context.Canceled) && !errors.Is(errs[1], context.Canceled) {
+		t.Errorf("errs = %v, want the cancellation of the parent", errs)
+	}
+}
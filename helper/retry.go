@@ -0,0 +1,78 @@
+package helper
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+const (
+	defaultBackoff    = 10 * time.Second
+	defaultMaxBackoff = 5 * time.Minute
+)
+
+// RetryPolicy how often a failed step runs again, the wait starts at Backoff
+// and doubles after every attempt up to MaxBackoff
+type RetryPolicy struct {
+	// Retries after the first attempt, 0 means the step runs once
+	Retries int
+	// Backoff before the first retry, default 10s
+	Backoff time.Duration
+	// MaxBackoff caps the wait between retries, default 5m
+	MaxBackoff time.Duration
+}
+
+// wait before retry number attempt, counting from 1
+func (p RetryPolicy) wait(attempt int) time.Duration {
+	backoff, maxBackoff := p.Backoff, p.MaxBackoff
+	if backoff <= 0 {
+		backoff = defaultBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+	for i := 1; i < attempt && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxBackoff)
+}
+
+// Retry runs fn until it succeeds, the retries of policy are used up or ctx
+// is done. attempt counts from 1.
+func Retry(ctx context.Context, policy RetryPolicy, stage string, fn func(attempt int) error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn(attempt)
+		if err == nil {
+			return nil
+		}
+
+		if ContextErr(ctx) != nil {
+			return err
+		}
+		if attempt > policy.Retries {
+			if policy.Retries > 0 {
+				slog.Error("Giving up after retries",
+					"stage", stage,
+					"attempts", attempt,
+					"error", err)
+			}
+			return err
+		}
+
+		wait := policy.wait(attempt)
+		slog.Warn("Attempt failed, retrying",
+			"stage", stage,
+			"attempt", attempt,
+			"retries", policy.Retries,
+			"wait", wait,
+			"error", err)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
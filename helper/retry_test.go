@@ -0,0 +1,127 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyWait(t *testing.T) {
+	cases := []struct {
+		name   string
+		policy RetryPolicy
+		want   []time.Duration
+	}{
+		{
+			name:   "defaults",
+			policy: RetryPolicy{},
+			want:   []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, 80 * time.Second, 160 * time.Second, 5 * time.Minute, 5 * time.Minute},
+		},
+		{
+			name:   "capped",
+			policy: RetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second},
+			want:   []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second},
+		},
+		{
+			name:   "max below backoff",
+			policy: RetryPolicy{Backoff: time.Minute, MaxBackoff: time.Second},
+			want:   []time.Duration{time.Second, time.Second},
+		},
+		{
+			name:   "no overflow",
+			policy: RetryPolicy{Backoff: time.Hour, MaxBackoff: 1 << 62},
+			want:   []time.Duration{time.Hour, 2 * time.Hour},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			for i, want := range c.want {
+				if got := c.policy.wait(i + 1); got != want {
+					t.Errorf("wait(%d) = %s, want %s", i+1, got, want)
+				}
+			}
+			if got := c.policy.wait(200); got <= 0 {
+				t.Errorf("wait(200) = %s, want positive", got)
+			}
+		})
+	}
+}
+
+func TestRetry(t *testing.T) {
+	errFlaky := errors.New("flaky")
+	fast := RetryPolicy{Backoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	cases := []struct {
+		name      string
+		retries   int
+		failures  int
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "success", retries: 0, failures: 0, wantCalls: 1},
+		{name: "no retries", retries: 0, failures: 1, wantCalls: 1, wantErr: true},
+		{name: "recovers", retries: 3, failures: 2, wantCalls: 3},
+		{name: "last retry", retries: 2, failures: 2, wantCalls: 3},
+		{name: "gives up", retries: 2, failures: 5, wantCalls: 3, wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			policy := fast
+			policy.Retries = c.retries
+			calls := 0
+			err := Retry(context.Background(), policy, "test", func(attempt int) error {
+				calls++
+				if attempt != calls {
+					t.Errorf("attempt = %d, want %d", attempt, calls)
+				}
+				if calls <= c.failures {
+					return errFlaky
+				}
+				return nil
+			})
+			if calls != c.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, c.wantCalls)
+			}
+			if (err != nil) != c.wantErr || (err != nil && !errors.Is(err, errFlaky)) {
+				t.Errorf("Retry() error = %v, want error %v", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetryStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := RetryPolicy{Retries: 5, Backoff: time.Hour}
+
+	calls := 0
+	done := make(chan error, 1)
+	go func() {
+		done <- Retry(ctx, policy, "test", func(attempt int) error {
+			calls++
+			return errors.New("down")
+		})
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if err == nil || calls != 1 {
+			t.Errorf("Retry() = %v after %d calls, want error after 1", err, calls)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Retry() kept waiting after cancel")
+	}
+
+	calls = 0
+	err := Retry(ctx, policy, "test", func(attempt int) error {
+		calls++
+		return errors.New("down")
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("Retry() on done ctx = %v after %d calls, want no retry", err, calls)
+	}
+}
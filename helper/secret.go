@@ -0,0 +1,84 @@
+package helper
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// minSecretLength shorter values are only masked as a whole argument, or
+// as the value of a --key=value argument, a password like "1" would turn
+// every digit of the logs into ******
+const minSecretLength = 4
+
+var (
+	secretsMu sync.RWMutex
+	secrets   []string
+)
+
+// RegisterSecret marks value to be masked whenever commands are logged
+func RegisterSecret(value string) {
+	if len(value) == 0 {
+		return
+	}
+
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	for _, secret := range secrets {
+		if secret == value {
+			return
+		}
+	}
+	secrets = append(secrets, value)
+	// the longest first, a secret containing another is masked as a whole
+	sort.SliceStable(secrets, func(i, j int) bool {
+		return len(secrets[i]) > len(secrets[j])
+	})
+}
+
+// Redact replaces every registered secret in s with ******. Secrets shorter
+// than minSecretLength are only replaced when they make a whole space
+// separated argument or the value of one.
+func Redact(s string) string {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	if len(secrets) == 0 {
+		return s
+	}
+
+	short := false
+	for _, secret := range secrets {
+		if len(secret) < minSecretLength {
+			short = true
+			continue
+		}
+		s = strings.ReplaceAll(s, secret, "******")
+	}
+	if !short {
+		return s
+	}
+
+	args := strings.Split(s, " ")
+	for i, arg := range args {
+		args[i] = redactShortArg(arg)
+	}
+	return strings.Join(args, " ")
+}
+
+// redactShortArg masks arg, or the value of a --key=value arg, when it
+// equals a secret shorter than minSecretLength
+func redactShortArg(arg string) string {
+	key, value, hasKey := strings.Cut(arg, "=")
+	for _, secret := range secrets {
+		if len(secret) >= minSecretLength {
+			continue
+		}
+		switch {
+		case arg == secret:
+			return "******"
+		case hasKey && value == secret:
+			return key + "=******"
+		}
+	}
+	return arg
+}
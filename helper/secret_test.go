@@ -0,0 +1,38 @@
+package helper
+
+import "testing"
+
+func TestRedact(t *testing.T) {
+	secretsMu.Lock()
+	saved := secrets
+	secrets = nil
+	secretsMu.Unlock()
+	defer func() {
+		secretsMu.Lock()
+		secrets = saved
+		secretsMu.Unlock()
+	}()
+
+	for _, value := range []string{"", "1", "abc", "hunter2", "hunter2-long", "correct horse battery"} {
+		RegisterSecret(value)
+	}
+
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{in: "mysql -u root db1", want: "mysql -u root db1"},
+		{in: "abc --password=hunter2", want: "****** --password=******"},
+		{in: "-u abcd --password=abc 1", want: "-u abcd --password=****** ******"},
+		{in: "dump --port=3310 db1", want: "dump --port=3310 db1"},
+		{in: "-c mysql -pcorrect horse battery db", want: "-c mysql -p****** db"},
+		{in: "--password=correct horse battery", want: "--password=******"},
+		{in: "hunter2-long", want: "******"},
+		{in: "hunter2 hunter2-long", want: "****** ******"},
+	}
+	for _, c := range cases {
+		if got := Redact(c.in); got != c.want {
+			t.Errorf("Redact(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
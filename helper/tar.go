@@ -0,0 +1,84 @@
+package helper
+
+import (
+	"archive/tar"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// UnreadableError is returned by AddTarEntry when filePath can't be read,
+// nothing was written to the tar for it
+type UnreadableError struct {
+	Path string
+	Err  error
+}
+
+func (e *UnreadableError) Error() string {
+	return "read " + e.Path + " failed: " + e.Err.Error()
+}
+
+func (e *UnreadableError) Unwrap() error {
+	return e.Err
+}
+
+// AddTarEntry writes filePath into tw as name, the content of regular files
+// follows the header. A path that can't be read fails with *UnreadableError
+// before anything is written, callers may skip it like GNU tar
+// --ignore-failed-read.
+func AddTarEntry(tw *tar.Writer, filePath, name string, d fs.DirEntry) error {
+	info, err := d.Info()
+	if err != nil {
+		return &UnreadableError{Path: filePath, Err: err}
+	}
+
+	link := ""
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if link, err = os.Readlink(filePath); err != nil {
+			return &UnreadableError{Path: filePath, Err: err}
+		}
+	}
+
+	var file *os.File
+	if info.Mode().IsRegular() {
+		// open before the header, so unreadable files are skipped cleanly
+		if file, err = os.Open(filePath); err != nil {
+			return &UnreadableError{Path: filePath, Err: err}
+		}
+		defer file.Close()
+	}
+
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return &UnreadableError{Path: filePath, Err: err}
+	}
+	header.Name = filepath.ToSlash(name)
+	if info.IsDir() {
+		header.Name += "/"
+	}
+
+	if err = tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if file == nil {
+		return nil
+	}
+
+	// the file may shrink while reading, the header size must still be met
+	n, err := io.Copy(tw, io.LimitReader(file, header.Size))
+	if err != nil {
+		return err
+	}
+	if n < header.Size {
+		_, err = io.CopyN(tw, zeroReader{}, header.Size-n)
+	}
+	return err
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
@@ -4,20 +4,6 @@ import (
 	"strings"
 )
 
-var (
-	// IsGnuTar show tar type
-	IsGnuTar = false
-)
-
-func init() {
-	checkIsGnuTar()
-}
-
-func checkIsGnuTar() {
-	out, _ := Exec("tar", "--version")
-	IsGnuTar = strings.Contains(out, "GNU")
-}
-
 // CleanHost clean host url ftp://foo.bar.com -> foo.bar.com
 func CleanHost(host string) string {
 	// ftp://ftp.your-host.com -> ftp.your-host.com
@@ -1,6 +1,10 @@
 package model
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
 
@@ -18,12 +22,30 @@ type Model struct {
 	Config config.ModelConfig
 }
 
-// Perform executes the backup process for the model.
-func (m *Model) Perform() {
+// StageError tells which stage of the backup failed.
+type StageError struct {
+	Stage string
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return e.Stage + ": " + e.Err.Error()
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// Perform executes the backup process for the model, the returned error is
+// a *StageError of the first stage that failed. Cancelling ctx stops the
+// running stage and kills its commands, the after script still runs.
+func (m *Model) Perform(ctx context.Context) (err error) {
+	m.Config.NewWorkDir()
 	slog.Info("Backup model starting",
 		"component", "model",
 		"model", m.Config.Name,
 		"workDir", m.Config.DumpPath,
+		"dryRun", helper.IsDryRun(ctx),
 	)
 
 	// Ensure cleanup is always called, even on panic.
@@ -34,70 +56,181 @@ func (m *Model) Perform() {
 				"model", m.Config.Name,
 				"error", r,
 			)
+			err = &StageError{Stage: "panic", Err: fmt.Errorf("%v", r)}
+		}
+		if cleanupErr := m.cleanup(context.WithoutCancel(ctx)); cleanupErr != nil && err == nil {
+			err = cleanupErr
 		}
-		m.cleanup()
 	}()
 
-	if err := m.runScript(m.Config.BeforeScript, "before"); err != nil {
+	// the backup goes on when before script failed, but the model fails
+	var beforeErr error
+	if err := m.runScript(ctx, m.Config.BeforeScript, "before"); err != nil {
 		slog.Error("Before script execution failed",
 			"component", "model",
 			"model", m.Config.Name,
 			"error", err,
 		)
+		beforeErr = &StageError{Stage: "before_script", Err: err}
 	}
 
-	if err := database.Run(m.Config); err != nil {
+	if m.Config.TempFiles {
+		err = m.performFiles(ctx)
+	} else {
+		err = m.performStream(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	return beforeErr
+}
+
+// performFiles writes every stage to a file under TempPath.
+func (m *Model) performFiles(ctx context.Context) error {
+	if err := database.Run(ctx, m.Config); err != nil {
 		slog.Error("Database backup failed",
 			"component", "model",
 			"model", m.Config.Name,
 			"error", err,
 		)
-		return
+		return &StageError{Stage: "database", Err: err}
 	}
 
 	if m.Config.Archive != nil {
-		if err := archive.Run(m.Config); err != nil {
+		if err := archive.Run(ctx, m.Config); err != nil {
 			slog.Error("Archive creation failed",
 				"component", "model",
 				"model", m.Config.Name,
 				"error", err,
 			)
-			return
+			return &StageError{Stage: "archive", Err: err}
 		}
 	}
 
-	archivePath, err := compressor.Run(m.Config)
+	archivePath, err := compressor.Run(ctx, m.Config)
 	if err != nil {
 		slog.Error("Compression failed",
 			"component", "model",
 			"model", m.Config.Name,
 			"error", err,
 		)
-		return
+		return &StageError{Stage: "compressor", Err: err}
 	}
 
-	archivePath, err = encryptor.Run(archivePath, m.Config)
+	archivePath, err = encryptor.Run(ctx, archivePath, m.Config)
 	if err != nil {
 		slog.Error("Encryption failed",
 			"component", "model",
 			"model", m.Config.Name,
 			"error", err,
 		)
-		return
+		return &StageError{Stage: "encryptor", Err: err}
 	}
 
-	if err := storage.Run(m.Config, archivePath); err != nil {
+	if err := storage.Run(ctx, m.Config, archivePath); err != nil {
 		slog.Error("Storage operation failed",
 			"component", "model",
 			"model", m.Config.Name,
 			"error", err,
 		)
-		return
+		return &StageError{Stage: "storage", Err: err}
+	}
+
+	return nil
+}
+
+// performStream pipes the dumps through compressor and encryptor straight
+// into the storages, nothing is written to TempPath.
+func (m *Model) performStream(ctx context.Context) error {
+	fileName, err := compressor.FileName(m.Config)
+	if err != nil {
+		return &StageError{Stage: "compressor", Err: err}
+	}
+	ext, err := encryptor.Ext(m.Config)
+	if err != nil {
+		return &StageError{Stage: "encryptor", Err: err}
+	}
+
+	err = storage.RunStream(ctx, m.Config, fileName+ext, func(w io.Writer) error {
+		return m.produce(ctx, w)
+	})
+	if err != nil {
+		stageErr := &StageError{Stage: "storage", Err: err}
+		errors.As(err, &stageErr)
+		slog.Error("Backup stage failed",
+			"component", "model",
+			"model", m.Config.Name,
+			"stage", stageErr.Stage,
+			"error", stageErr.Err,
+		)
+		return stageErr
 	}
+	return nil
 }
 
-// runScript executes a shell script if provided.
-func (m *Model) runScript(script string, stage string) error {
+// produce writes the encrypted and compressed tar of the model into w. The
+// stages run at the same time, so the compress_with and encrypt_with
+// timeouts limit everything that passes through them.
+func (m *Model) produce(ctx context.Context, w io.Writer) error {
+	encryptCtx, cancel := helper.WithTimeout(ctx, "encrypt", m.Config.EncryptWith.Timeout)
+	defer cancel()
+	compressCtx, cancel := helper.WithTimeout(encryptCtx, "compress", m.Config.CompressWith.Timeout)
+	defer cancel()
+
+	ew, err := encryptor.NewWriter(encryptCtx, m.Config, w)
+	if err != nil {
+		return &StageError{Stage: "encryptor", Err: err}
+	}
+	cw, err := compressor.NewWriter(compressCtx, m.Config, ew)
+	if err != nil {
+		ew.Close()
+		return &StageError{Stage: "compressor", Err: err}
+	}
+	tw := compressor.NewTarWriter(cw, m.Config.Name, m.Config.ChunkSize)
+
+	err = m.produceEntries(compressCtx, tw)
+	if err == nil {
+		if err = tw.Close(); err != nil {
+			err = &StageError{Stage: "compressor", Err: err}
+		}
+	}
+	if closeErr := cw.Close(); closeErr != nil && err == nil {
+		err = &StageError{Stage: "compressor", Err: closeErr}
+	}
+	if closeErr := ew.Close(); closeErr != nil && err == nil {
+		err = &StageError{Stage: "encryptor", Err: closeErr}
+	}
+	return err
+}
+
+func (m *Model) produceEntries(ctx context.Context, tw *compressor.TarWriter) error {
+	if err := database.RunStream(ctx, m.Config, tw.Entry); err != nil {
+		return &StageError{Stage: "database", Err: err}
+	}
+
+	if m.Config.Archive != nil {
+		w, err := tw.Entry("archive.tar")
+		if err != nil {
+			return &StageError{Stage: "archive", Err: err}
+		}
+		if helper.IsDryRun(ctx) {
+			err = archive.Run(ctx, m.Config)
+		} else {
+			err = archive.Stream(ctx, m.Config, w)
+		}
+		if closeErr := w.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return &StageError{Stage: "archive", Err: err}
+		}
+	}
+	return nil
+}
+
+// runScript executes a shell script with sh -c if provided.
+func (m *Model) runScript(ctx context.Context, script string, stage string) error {
 	if len(script) == 0 {
 		return nil
 	}
@@ -105,12 +238,11 @@ func (m *Model) runScript(script string, stage string) error {
 		"component", "model",
 		"model", m.Config.Name,
 	)
-	_, err := helper.ExecWithStdio(script, true)
-	return err
+	return helper.ExecScript(ctx, script)
 }
 
 // cleanup removes temporary files and runs the after script.
-func (m *Model) cleanup() {
+func (m *Model) cleanup(ctx context.Context) (err error) {
 	slog.Info("Cleaning up temporary files",
 		"component", "model",
 		"model", m.Config.Name,
@@ -126,16 +258,18 @@ func (m *Model) cleanup() {
 		)
 	}
 
-	if err := m.runScript(m.Config.AfterScript, "after"); err != nil {
+	if scriptErr := m.runScript(ctx, m.Config.AfterScript, "after"); scriptErr != nil {
 		slog.Error("After script execution failed",
 			"component", "model",
 			"model", m.Config.Name,
-			"error", err,
+			"error", scriptErr,
 		)
+		err = &StageError{Stage: "after_script", Err: scriptErr}
 	}
 
 	slog.Info("Backup model completed",
 		"component", "model",
 		"model", m.Config.Name,
 	)
+	return
 }
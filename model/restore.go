@@ -0,0 +1,76 @@
+package model
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/holgerhuo/gobackup/compressor"
+	"github.com/holgerhuo/gobackup/database"
+	"github.com/holgerhuo/gobackup/encryptor"
+	"github.com/holgerhuo/gobackup/helper"
+	"github.com/holgerhuo/gobackup/storage"
+)
+
+// RestoreOptions for Model.Restore
+type RestoreOptions struct {
+	// Storage name to fetch from, default to the first storage of the model
+	Storage string
+	// FileKey of the backup, default to the latest one
+	FileKey string
+	// Dir keeps the downloaded and extracted backup,
+	// a temporary directory removed afterwards is used when empty
+	Dir string
+	// SkipDatabases only extracts the backup
+	SkipDatabases bool
+	// Identity overrides the identity_file of an age or gpg encryptor
+	Identity string
+	Database database.RestoreOptions
+}
+
+// Restore fetches a backup of the model from storage, decrypts and extracts it,
+// then restores every configured database. Cancelling ctx stops it.
+func (m *Model) Restore(ctx context.Context, opts RestoreOptions) (err error) {
+	dir := opts.Dir
+	if len(dir) == 0 {
+		m.Config.NewWorkDir()
+		dir = m.Config.TempPath
+		defer os.RemoveAll(m.Config.TempPath)
+	}
+	helper.MkdirP(dir)
+
+	slog.Info("Restore model starting",
+		"component", "model",
+		"model", m.Config.Name,
+		"workDir", dir,
+	)
+
+	filePath, err := storage.Download(ctx, m.Config, opts.Storage, opts.FileKey, dir)
+	if err != nil {
+		return err
+	}
+
+	archivePath, err := encryptor.Decrypt(ctx, filePath, m.Config, opts.Identity)
+	if err != nil {
+		return err
+	}
+
+	if err = compressor.Extract(ctx, m.Config, archivePath, dir); err != nil {
+		return err
+	}
+
+	dumpPath := filepath.Join(dir, m.Config.Name)
+	if !opts.SkipDatabases {
+		if err = database.Restore(ctx, m.Config, dumpPath, opts.Database); err != nil {
+			return err
+		}
+	}
+
+	slog.Info("Restore model completed",
+		"component", "model",
+		"model", m.Config.Name,
+		"extractedPath", dumpPath,
+	)
+	return nil
+}
@@ -0,0 +1,21 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/holgerhuo/gobackup/compressor"
+	"github.com/holgerhuo/gobackup/database"
+	"github.com/holgerhuo/gobackup/encryptor"
+	"github.com/holgerhuo/gobackup/storage"
+)
+
+// Validate reports every unsupported database, compressor, encryptor and
+// storage type of the model, so a broken config fails before any work starts.
+func (m *Model) Validate() error {
+	return errors.Join(
+		database.Validate(m.Config),
+		compressor.Validate(m.Config),
+		encryptor.Validate(m.Config),
+		storage.Validate(m.Config),
+	)
+}
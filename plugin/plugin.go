@@ -0,0 +1,261 @@
+// Package plugin runs types of databases, storages, compressors and
+// encryptors that gobackup doesn't ship as external programs.
+//
+// A plugin is an executable named gobackup-<kind>-<type>, found in PATH or
+// ~/.gobackup/plugins, for example gobackup-storage-webdav is used by
+// `type: webdav` in store_with. Every call starts the plugin once and
+// writes a Request as a single JSON line to its stdin, the data of the call
+// follows on stdin or is expected on stdout:
+//
+//	method      kind        stdin after request  stdout
+//	describe    all         -                    Description as JSON
+//	dump        database    -                    dump file
+//	restore     database    dump file            -
+//	upload      storage     backup file          -
+//	download    storage     -                    backup file
+//	list        storage     -                    []Item as JSON
+//	delete      storage     -                    -
+//	compress    compressor  tar                  compressed tar
+//	decompress  compressor  compressed tar       tar
+//	encrypt     encryptor   backup file          encrypted backup
+//	decrypt     encryptor   encrypted backup     backup file
+//
+// A call fails when the plugin exits non zero, stderr is the error message.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/holgerhuo/gobackup/helper"
+)
+
+// Version of the protocol, sent in every Request
+const Version = 1
+
+// Kinds of plugins
+const (
+	KindDatabase   = "database"
+	KindStorage    = "storage"
+	KindCompressor = "compressor"
+	KindEncryptor  = "encryptor"
+)
+
+// Request written as the first line of stdin
+type Request struct {
+	Version int    `json:"version"`
+	Kind    string `json:"kind"`
+	Type    string `json:"type"`
+	Method  string `json:"method"`
+	// Model name, empty for describe
+	Model string `json:"model,omitempty"`
+	// Name of the database or storage entry
+	Name string `json:"name,omitempty"`
+	// Key of the backup file for storage calls
+	Key string `json:"key,omitempty"`
+	// Settings of the config entry, type included
+	Settings map[string]interface{} `json:"settings,omitempty"`
+	// Options of a database restore
+	Options map[string]string `json:"options,omitempty"`
+}
+
+// Description answered to describe
+type Description struct {
+	// Required and Optional keys of the config entry
+	Required []string `json:"required"`
+	Optional []string `json:"optional"`
+	// Ext of the file name, compressors and encryptors only, example: .tar.br
+	Ext string `json:"ext"`
+}
+
+// Item of a storage list
+type Item struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// Plugin executable of a type
+type Plugin struct {
+	Kind string
+	Type string
+	Path string
+}
+
+var (
+	// Dirs searched for plugins before PATH
+	Dirs = []string{"~/.gobackup/plugins"}
+
+	descriptionsMu sync.Mutex
+	descriptions   = map[string]Description{}
+
+	// describeTimeout bounds the describe call, it runs while loading config
+	describeTimeout = 10 * time.Second
+)
+
+// Lookup finds the plugin of a type, false when there is none
+func Lookup(kind, typ string) (*Plugin, bool) {
+	if len(typ) == 0 || strings.ContainsAny(typ, `/\`) {
+		return nil, false
+	}
+
+	name := "gobackup-" + kind + "-" + typ
+	for _, dir := range Dirs {
+		filePath := filepath.Join(helper.ExplandHome(dir), name)
+		if info, err := os.Stat(filePath); err == nil && info.Mode().IsRegular() && info.Mode()&0111 != 0 {
+			return &Plugin{Kind: kind, Type: typ, Path: filePath}, true
+		}
+	}
+	if filePath, err := exec.LookPath(name); err == nil {
+		return &Plugin{Kind: kind, Type: typ, Path: filePath}, true
+	}
+	return nil, false
+}
+
+// NewRequest for method of the plugin
+func (p *Plugin) NewRequest(method string) Request {
+	return Request{
+		Version: Version,
+		Kind:    p.Kind,
+		Type:    p.Type,
+		Method:  method,
+	}
+}
+
+// Describe asks the plugin for its config keys and extension, the answer
+// is cached. It also runs in dry run, a plugin not answering within
+// describeTimeout is killed with its children.
+func (p *Plugin) Describe() (desc Description, err error) {
+	descriptionsMu.Lock()
+	desc, ok := descriptions[p.Path]
+	descriptionsMu.Unlock()
+	if ok {
+		return desc, nil
+	}
+
+	line, err := requestLine(p.NewRequest("describe"))
+	if err != nil {
+		return
+	}
+	// a context of its own, describe runs in dry run too
+	ctx, cancel := helper.WithTimeout(context.Background(), "plugin describe", describeTimeout)
+	defer cancel()
+
+	var stdout bytes.Buffer
+	if err = helper.ExecStream(ctx, p.Path, nil, bytes.NewReader(line), &stdout); err != nil {
+		return desc, fmt.Errorf("plugin %s describe: %s", p.Path, strings.TrimSpace(err.Error()))
+	}
+	if err = json.Unmarshal(stdout.Bytes(), &desc); err != nil {
+		return desc, fmt.Errorf("plugin %s describe: invalid response: %s", p.Path, err)
+	}
+
+	descriptionsMu.Lock()
+	descriptions[p.Path] = desc
+	descriptionsMu.Unlock()
+	return desc, nil
+}
+
+// Call runs req with data after the request on stdin, stdout of the plugin
+// goes to w, either may be nil. The plugin is killed when ctx is done.
+func (p *Plugin) Call(ctx context.Context, req Request, data io.Reader, w io.Writer) error {
+	line, err := requestLine(req)
+	if err != nil {
+		return err
+	}
+
+	stdin := io.Reader(bytes.NewReader(line))
+	if data != nil {
+		stdin = io.MultiReader(stdin, data)
+	}
+	if err = helper.ExecStream(ctx, p.Path, nil, stdin, w); err != nil {
+		return fmt.Errorf("plugin %s %s: %s", p.Path, req.Method, strings.TrimSpace(err.Error()))
+	}
+	return nil
+}
+
+// CallJSON runs req and decodes the JSON stdout into out
+func (p *Plugin) CallJSON(ctx context.Context, req Request, out interface{}) error {
+	var stdout bytes.Buffer
+	if err := p.Call(ctx, req, nil, &stdout); err != nil {
+		return err
+	}
+	if helper.IsDryRun(ctx) {
+		return nil
+	}
+	if err := json.Unmarshal(stdout.Bytes(), out); err != nil {
+		return fmt.Errorf("plugin %s %s: invalid response: %s", p.Path, req.Method, err)
+	}
+	return nil
+}
+
+// NewWriter runs req in background, everything written into the writer
+// goes to stdin of the plugin and its stdout into w. Close waits for the
+// plugin to exit.
+func (p *Plugin) NewWriter(ctx context.Context, req Request, w io.Writer) io.WriteCloser {
+	pr, pw := io.Pipe()
+	cw := &callWriter{PipeWriter: pw, done: make(chan error, 1)}
+	go func() {
+		err := p.Call(ctx, req, pr, w)
+		pr.CloseWithError(err)
+		cw.done <- err
+	}()
+	return cw
+}
+
+// NewReader runs req in background with r after the request on stdin, the
+// reader returns stdout of the plugin. Close waits for the plugin to exit.
+func (p *Plugin) NewReader(ctx context.Context, req Request, r io.Reader) io.ReadCloser {
+	pr, pw := io.Pipe()
+	cr := &callReader{PipeReader: pr, done: make(chan error, 1)}
+	go func() {
+		err := p.Call(ctx, req, r, pw)
+		pw.CloseWithError(err)
+		cr.done <- err
+	}()
+	return cr
+}
+
+func requestLine(req Request) ([]byte, error) {
+	line, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("plugin request: %s", err)
+	}
+	return append(line, '\n'), nil
+}
+
+type callWriter struct {
+	*io.PipeWriter
+	done chan error
+}
+
+// Close waits the plugin to exit
+func (w *callWriter) Close() error {
+	w.PipeWriter.Close()
+	return <-w.done
+}
+
+type callReader struct {
+	*io.PipeReader
+	done chan error
+}
+
+// Close waits the plugin to exit, a plugin stopped before its output was
+// read completely is not an error of the reader
+func (r *callReader) Close() error {
+	r.PipeReader.Close()
+	err := <-r.done
+	if errors.Is(err, io.ErrClosedPipe) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,211 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubPlugin writes script as the gobackup-<kind>-<typ> plugin in a temp dir
+// listed in Dirs
+func stubPlugin(t *testing.T, kind, typ, script string) *Plugin {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "gobackup-"+kind+"-"+typ)
+	if err := os.WriteFile(filePath, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	dirs := Dirs
+	Dirs = []string{dir}
+	t.Cleanup(func() { Dirs = dirs })
+
+	p, ok := Lookup(kind, typ)
+	if !ok {
+		t.Fatalf("Lookup(%q, %q) found no plugin", kind, typ)
+	}
+	if p.Path != filePath {
+		t.Fatalf("Lookup() path = %q, want %q", p.Path, filePath)
+	}
+	return p
+}
+
+func TestLookup(t *testing.T) {
+	stubPlugin(t, KindStorage, "stub", "exit 0\n")
+
+	if _, ok := Lookup(KindStorage, "missing"); ok {
+		t.Errorf("Lookup() found a missing plugin")
+	}
+	if _, ok := Lookup(KindDatabase, "stub"); ok {
+		t.Errorf("Lookup() found the plugin of another kind")
+	}
+	if _, ok := Lookup(KindStorage, "../stub"); ok {
+		t.Errorf("Lookup() accepted a type with a path")
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	countPath := filepath.Join(t.TempDir(), "count")
+	p := stubPlugin(t, KindCompressor, "stub", `read -r req
+echo "$req" >> `+countPath+`
+echo '{"required":["level"],"optional":["threads"],"ext":".tar.stub"}'
+`)
+
+	want := Description{Required: []string{"level"}, Optional: []string{"threads"}, Ext: ".tar.stub"}
+	for i := 0; i < 2; i++ {
+		desc, err := p.Describe()
+		if err != nil {
+			t.Fatalf("Describe() error = %v", err)
+		}
+		if !reflect.DeepEqual(desc, want) {
+			t.Errorf("Describe() = %+v, want %+v", desc, want)
+		}
+	}
+
+	data, err := os.ReadFile(countPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("plugin described %d times, want 1 with the cache", len(lines))
+	}
+	var req Request
+	if err = json.Unmarshal([]byte(lines[0]), &req); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(req, Request{Version: Version, Kind: KindCompressor, Type: "stub", Method: "describe"}) {
+		t.Errorf("describe request = %+v", req)
+	}
+}
+
+func TestDescribeInvalid(t *testing.T) {
+	p := stubPlugin(t, KindEncryptor, "stub", "echo not json\n")
+	if _, err := p.Describe(); err == nil || !strings.Contains(err.Error(), "invalid response") {
+		t.Errorf("Describe() error = %v, want invalid response", err)
+	}
+}
+
+func TestDescribeTimeout(t *testing.T) {
+	timeout := describeTimeout
+	describeTimeout = 200 * time.Millisecond
+	t.Cleanup(func() { describeTimeout = timeout })
+
+	// sleep runs as a child, it must be killed too
+	p := stubPlugin(t, KindDatabase, "stub", "sleep 30\necho '{}'\n")
+	start := time.Now()
+	if _, err := p.Describe(); err == nil {
+		t.Fatal("Describe() error = nil, want timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Describe() returned after %s", elapsed)
+	}
+}
+
+func TestCallJSON(t *testing.T) {
+	p := stubPlugin(t, KindStorage, "stub", `read -r req
+case "$req" in
+*'"method":"list"'*) echo '[{"key":"app.2024.01.02.03.04.05.tar","size":3}]' ;;
+*) echo 'not json' ;;
+esac
+`)
+
+	var items []Item
+	if err := p.CallJSON(context.Background(), p.NewRequest("list"), &items); err != nil {
+		t.Fatalf("CallJSON() error = %v", err)
+	}
+	if len(items) != 1 || items[0].Key != "app.2024.01.02.03.04.05.tar" || items[0].Size != 3 {
+		t.Errorf("CallJSON() items = %+v", items)
+	}
+
+	err := p.CallJSON(context.Background(), p.NewRequest("delete"), &items)
+	if err == nil || !strings.Contains(err.Error(), "invalid response") {
+		t.Errorf("CallJSON() error = %v, want invalid response", err)
+	}
+}
+
+func TestCallError(t *testing.T) {
+	p := stubPlugin(t, KindStorage, "stub", "echo 'bucket not found' >&2\nexit 1\n")
+	err := p.Call(context.Background(), p.NewRequest("upload"), strings.NewReader("data"), nil)
+	if err == nil || !strings.Contains(err.Error(), "bucket not found") {
+		t.Errorf("Call() error = %v, want stderr of the plugin", err)
+	}
+}
+
+func TestNewWriter(t *testing.T) {
+	reqPath := filepath.Join(t.TempDir(), "request")
+	p := stubPlugin(t, KindEncryptor, "stub", "read -r req\necho \"$req\" > "+reqPath+"\ncat\n")
+
+	req := p.NewRequest("encrypt")
+	req.Model = "app"
+	req.Settings = map[string]interface{}{"type": "stub"}
+
+	var out bytes.Buffer
+	w := p.NewWriter(context.Background(), req, &out)
+	if _, err := io.WriteString(w, "backup data"); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if out.String() != "backup data" {
+		t.Errorf("plugin output = %q", out.String())
+	}
+
+	data, err := os.ReadFile(reqPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Request
+	if err = json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Method != "encrypt" || got.Model != "app" || got.Settings["type"] != "stub" {
+		t.Errorf("request = %+v", got)
+	}
+}
+
+func TestNewWriterError(t *testing.T) {
+	p := stubPlugin(t, KindCompressor, "stub", "echo 'bad level' >&2\nexit 1\n")
+
+	w := p.NewWriter(context.Background(), p.NewRequest("compress"), io.Discard)
+	// the write may fail already when the plugin exited before
+	io.WriteString(w, "tar data")
+	if err := w.Close(); err == nil || !strings.Contains(err.Error(), "bad level") {
+		t.Errorf("Close() error = %v, want stderr of the plugin", err)
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	p := stubPlugin(t, KindCompressor, "stub", "read -r req\ncat\n")
+
+	r := p.NewReader(context.Background(), p.NewRequest("decompress"), strings.NewReader("tar data"))
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if string(data) != "tar data" {
+		t.Errorf("plugin output = %q", data)
+	}
+	if err = r.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
+
+func TestNewReaderError(t *testing.T) {
+	p := stubPlugin(t, KindCompressor, "stub", "echo 'corrupt input' >&2\nexit 1\n")
+
+	r := p.NewReader(context.Background(), p.NewRequest("decompress"), strings.NewReader("tar data"))
+	if _, err := io.ReadAll(r); err == nil || !strings.Contains(err.Error(), "corrupt input") {
+		t.Errorf("ReadAll() error = %v, want stderr of the plugin", err)
+	}
+	if err := r.Close(); err == nil || !strings.Contains(err.Error(), "corrupt input") {
+		t.Errorf("Close() error = %v, want stderr of the plugin", err)
+	}
+}
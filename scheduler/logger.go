@@ -0,0 +1,34 @@
+package scheduler
+
+import (
+	"log/slog"
+)
+
+// cronLogger sends cron logs to slog, the chatty ones at debug level. The
+// logger of a job has the name of its model.
+type cronLogger struct {
+	model string
+}
+
+func (l cronLogger) Info(msg string, keysAndValues ...interface{}) {
+	if msg == "skip" {
+		slog.Warn("Model is still running, skipped this tick",
+			"component", "scheduler",
+			"model", l.model)
+		return
+	}
+	slog.Debug("cron "+msg, l.args(keysAndValues)...)
+}
+
+func (l cronLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	args := append([]any{"error", err}, keysAndValues...)
+	slog.Error("cron "+msg, l.args(args)...)
+}
+
+func (l cronLogger) args(keysAndValues []interface{}) []any {
+	args := []any{"component", "scheduler"}
+	if len(l.model) > 0 {
+		args = append(args, "model", l.model)
+	}
+	return append(args, keysAndValues...)
+}
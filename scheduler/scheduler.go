@@ -0,0 +1,118 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/holgerhuo/gobackup/config"
+	"github.com/holgerhuo/gobackup/model"
+	"github.com/robfig/cron/v3"
+)
+
+// Run performs every model on its `schedule` or `every` config until ctx
+// is done, then waits for the backups in flight to finish.
+// A model never runs twice at the same time, a tick is skipped instead.
+// At most concurrency models perform at once, the others wait for a slot.
+func Run(ctx context.Context, models []config.ModelConfig, concurrency int) error {
+	c := cron.New(cron.WithLogger(cronLogger{}))
+	sem := make(chan struct{}, max(concurrency, 1))
+
+	scheduled := 0
+	for _, modelConfig := range models {
+		schedule, err := parseSchedule(modelConfig)
+		if err != nil {
+			return err
+		}
+		if schedule == nil {
+			slog.Info("Model has no schedule, skipped",
+				"component", "scheduler",
+				"model", modelConfig.Name)
+			continue
+		}
+
+		c.Schedule(schedule, newJob(ctx, modelConfig, sem))
+		scheduled++
+		slog.Info("Model scheduled",
+			"component", "scheduler",
+			"model", modelConfig.Name,
+			"schedule", modelConfig.Schedule,
+			"every", modelConfig.Every.String(),
+			"next", schedule.Next(time.Now()))
+	}
+
+	if scheduled == 0 {
+		return fmt.Errorf("no model has schedule or every config")
+	}
+
+	c.Start()
+	<-ctx.Done()
+
+	slog.Info("Scheduler stopping, waiting for running backups",
+		"component", "scheduler")
+	<-c.Stop().Done()
+	slog.Info("Scheduler stopped",
+		"component", "scheduler")
+	return nil
+}
+
+func parseSchedule(modelConfig config.ModelConfig) (cron.Schedule, error) {
+	if len(modelConfig.Schedule) > 0 && modelConfig.Every > 0 {
+		return nil, fmt.Errorf("model: %s config both schedule and every, only one is allowed", modelConfig.Name)
+	}
+
+	if len(modelConfig.Schedule) > 0 {
+		schedule, err := cron.ParseStandard(modelConfig.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("model: %s invalid schedule %q: %s", modelConfig.Name, modelConfig.Schedule, err)
+		}
+		return schedule, nil
+	}
+
+	if modelConfig.Every > 0 {
+		return cron.Every(modelConfig.Every), nil
+	}
+
+	return nil, nil
+}
+
+// newJob performs the model once it holds a slot of sem, a stopping
+// scheduler lets it finish so ctx is not cancelled for it, a job still
+// waiting for a slot is dropped. The outcome of every run, panics and
+// skipped ticks are logged with the model name.
+func newJob(ctx context.Context, modelConfig config.ModelConfig, sem chan struct{}) cron.Job {
+	stopping := ctx.Done()
+	ctx = context.WithoutCancel(ctx)
+	logger := cronLogger{model: modelConfig.Name}
+	return cron.NewChain(cron.Recover(logger), cron.SkipIfStillRunning(logger)).Then(cron.FuncJob(func() {
+		select {
+		case sem <- struct{}{}:
+			defer func() { <-sem }()
+		case <-stopping:
+			slog.Info("Scheduler stopping, waiting backup dropped",
+				"component", "scheduler",
+				"model", modelConfig.Name)
+			return
+		}
+
+		m := model.Model{
+			Config: modelConfig,
+		}
+		startedAt := time.Now()
+		err := m.Perform(ctx)
+		duration := time.Since(startedAt).Round(time.Millisecond).String()
+		if err != nil {
+			slog.Error("Scheduled backup failed",
+				"component", "scheduler",
+				"model", modelConfig.Name,
+				"duration", duration,
+				"error", err)
+			return
+		}
+		slog.Info("Scheduled backup succeeded",
+			"component", "scheduler",
+			"model", modelConfig.Name,
+			"duration", duration)
+	}))
+}
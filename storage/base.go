@@ -1,75 +1,378 @@
 package storage
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"os"
+	"path"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/holgerhuo/gobackup/config"
+	"github.com/holgerhuo/gobackup/helper"
+	"github.com/holgerhuo/gobackup/plugin"
 	"github.com/spf13/viper"
 )
 
 // Base storage
 type Base struct {
-	model       config.ModelConfig
-	archivePath string
-	viper       *viper.Viper
+	model   config.ModelConfig
+	storage config.SubConfig
+	viper   *viper.Viper
+}
+
+// FileItem a backup file kept in storage
+type FileItem struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
 }
 
 // Context storage interface
 type Context interface {
 	open() error
 	close()
-	upload(fileKey string) error
+	// upload reads r until EOF into fileKey, a partial upload is removed
+	// when r fails
+	upload(runCtx context.Context, fileKey string, r io.Reader) error
+	list(runCtx context.Context) ([]FileItem, error)
+	delete(runCtx context.Context, fileKey string) error
+	download(runCtx context.Context, fileKey, destPath string) error
 }
 
-func newBase(model config.ModelConfig, archivePath string) (base Base) {
+func newBase(model config.ModelConfig, storage config.SubConfig) (base Base) {
 	base = Base{
-		model:       model,
-		archivePath: archivePath,
-		viper:       model.StoreWith.Viper,
+		model:   model,
+		storage: storage,
+		viper:   storage.Viper,
 	}
 
 	return
 }
 
-// Run storage
-func Run(model config.ModelConfig, archivePath string) (err error) {
-	slog.Info("Starting storage operation", 
+// destinations returns store_with followed by every entry of storages
+func destinations(model config.ModelConfig) (storages []config.SubConfig) {
+	if len(model.StoreWith.Type) > 0 {
+		storages = append(storages, model.StoreWith)
+	}
+	storages = append(storages, model.Storages...)
+	return
+}
+
+// Validate check model has storages and all of them have a supported type
+func Validate(model config.ModelConfig) error {
+	storages := destinations(model)
+	if len(storages) == 0 {
+		return fmt.Errorf("model: %s has no storage config", model.Name)
+	}
+
+	var errs []error
+	for _, storage := range storages {
+		if _, err := newContext(model, storage); err != nil {
+			errs = append(errs, fmt.Errorf("model: %s storage %s: %s", model.Name, storage.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run storage, uploads the file at archivePath to every storage of model at
+// the same time. A failed upload is retried by the retry policy of its
+// storage, reading the file again.
+func Run(runCtx context.Context, model config.ModelConfig, archivePath string) (err error) {
+	storages := destinations(model)
+	if len(storages) == 0 {
+		return fmt.Errorf("model: %s has no storage config", model.Name)
+	}
+
+	fileKey := filepath.Base(archivePath)
+	slog.Info("Starting storage operation",
 		"component", "storage",
-		"model", model.Name)
-	
-	newFileKey := filepath.Base(archivePath)
-	base := newBase(model, archivePath)
-	var ctx Context
-	switch model.StoreWith.Type {
-	case "local":
-		ctx = &Local{Base: base}
-	case "s3":
-		ctx = &S3{Base: base}
-	default:
-		return fmt.Errorf("[%s] storage type has not implement", model.StoreWith.Type)
-	}
-
-	slog.Info("Storage operation details", 
+		"model", model.Name,
+		"fileKey", fileKey,
+		"count", len(storages))
+
+	results := helper.Parallel(len(storages), len(storages), false, func(i int) error {
+		return uploadFile(runCtx, model, storages[i], archivePath, fileKey)
+	})
+	return storagePolicy(model, storages, report(model, storages, results))
+}
+
+// uploadFile uploads the file at filePath to storage as fileKey, retried by
+// the retry policy of storage
+func uploadFile(runCtx context.Context, model config.ModelConfig, storage config.SubConfig, filePath, fileKey string) error {
+	return helper.Retry(runCtx, storage.Retry, "upload to "+storage.Name, func(attempt int) error {
+		if helper.IsDryRun(runCtx) {
+			return runStorage(runCtx, model, storage, fileKey, strings.NewReader(""))
+		}
+		file, err := os.Open(filePath)
+		if err != nil {
+			return fmt.Errorf("failed to open file %q, %v", filePath, err)
+		}
+		defer file.Close()
+		return runStorage(runCtx, model, storage, fileKey, file)
+	})
+}
+
+// RunStream uploads what produce writes to every storage of model at the
+// same time, as fileKey. A storage failing midway is dropped and the others
+// go on, storage_policy decides whether the model fails. An upload stops
+// when runCtx is done or the upload_timeout of its storage expired. A storage
+// with retries gets the stream spooled to a file, uploaded once it's complete.
+func RunStream(runCtx context.Context, model config.ModelConfig, fileKey string, produce func(w io.Writer) error) (err error) {
+	storages := destinations(model)
+	if len(storages) == 0 {
+		return fmt.Errorf("model: %s has no storage config", model.Name)
+	}
+
+	slog.Info("Starting storage operation",
+		"component", "storage",
+		"model", model.Name,
+		"fileKey", fileKey,
+		"count", len(storages))
+
+	uploads := make([]*pipeUpload, len(storages))
+	writers := make([]*io.PipeWriter, len(storages))
+	for i, storage := range storages {
+		pr, pw := io.Pipe()
+		uploads[i] = startUpload(runCtx, model, storage, fileKey, pr)
+		writers[i] = pw
+	}
+
+	produceErr := produce(newFanoutWriter(writers))
+	for _, pw := range writers {
+		pw.CloseWithError(produceErr)
+	}
+
+	results := make([]error, len(uploads))
+	for i, upload := range uploads {
+		results[i] = <-upload.done
+	}
+	errs := report(model, storages, results)
+
+	// the upload errors explain why every storage stopped reading
+	if produceErr != nil && !errors.Is(produceErr, errAllStoragesFailed) {
+		return produceErr
+	}
+	return storagePolicy(model, storages, errs)
+}
+
+// report logs the result of every storage, the failed ones are returned
+func report(model config.ModelConfig, storages []config.SubConfig, results []error) (errs []error) {
+	for i, err := range results {
+		storage := storages[i]
+		if err != nil {
+			slog.Error("Storage destination failed",
+				"component", "storage",
+				"model", model.Name,
+				"storage", storage.Name,
+				"type", storage.Type,
+				"error", err)
+			errs = append(errs, fmt.Errorf("storage %s: %w", storage.Name, err))
+			continue
+		}
+		slog.Info("Storage destination succeeded",
+			"component", "storage",
+			"model", model.Name,
+			"storage", storage.Name,
+			"type", storage.Type)
+	}
+
+	slog.Info("Storage operation completed",
+		"component", "storage",
+		"model", model.Name,
+		"policy", model.StoragePolicy,
+		"succeeded", len(storages)-len(errs),
+		"failed", len(errs))
+	return
+}
+
+// storagePolicy decides whether the failed storages fail the model
+func storagePolicy(model config.ModelConfig, storages []config.SubConfig, errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	if model.StoragePolicy == "any" && len(errs) < len(storages) {
+		slog.Warn("Some storage destinations failed, ignored by storage_policy: any",
+			"component", "storage",
+			"model", model.Name,
+			"failed", len(errs))
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+func newContext(model config.ModelConfig, storage config.SubConfig) (Context, error) {
+	base := newBase(model, storage)
+
+	registryMu.RLock()
+	fn, ok := registry[storage.Type]
+	registryMu.RUnlock()
+	if ok {
+		return fn(base), nil
+	}
+	if p, ok := plugin.Lookup(plugin.KindStorage, storage.Type); ok {
+		return &external{Base: base, factory: pluginFactory(p)}, nil
+	}
+	return nil, fmt.Errorf("[%s] storage type has not implement", storage.Type)
+}
+
+type pipeUpload struct {
+	done chan error
+}
+
+// startUpload uploads r to storage in background, r is closed with the
+// upload error so the writing side stops feeding a failed storage.
+func startUpload(runCtx context.Context, model config.ModelConfig, storage config.SubConfig, fileKey string, r *io.PipeReader) *pipeUpload {
+	upload := &pipeUpload{done: make(chan error, 1)}
+	go func() {
+		err := helper.Recover(func() error {
+			if storage.Retry.Retries > 0 && !helper.IsDryRun(runCtx) {
+				return spoolUpload(runCtx, model, storage, fileKey, r)
+			}
+			return runStorage(runCtx, model, storage, fileKey, r)
+		})
+		if err != nil {
+			r.CloseWithError(err)
+		} else {
+			// drain anything left, uploads stop at EOF
+			io.Copy(io.Discard, r)
+		}
+		upload.done <- err
+	}()
+	return upload
+}
+
+// spoolUpload writes r to a file in the temp path of model, the stream can't
+// be read twice, and uploads the file with the retries of storage
+func spoolUpload(runCtx context.Context, model config.ModelConfig, storage config.SubConfig, fileKey string, r io.Reader) error {
+	helper.MkdirP(model.TempPath)
+	file, err := os.CreateTemp(model.TempPath, storage.Name+"-*.spool")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	_, err = io.Copy(file, helper.NewContextReader(runCtx, r))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return uploadFile(runCtx, model, storage, file.Name(), fileKey)
+}
+
+func runStorage(runCtx context.Context, model config.ModelConfig, storage config.SubConfig, fileKey string, r io.Reader) (err error) {
+	ctx, err := newContext(model, storage)
+	if err != nil {
+		return err
+	}
+
+	if helper.IsDryRun(runCtx) {
+		slog.Info("Dry run, upload skipped",
+			"component", "storage",
+			"type", storage.Type,
+			"model", model.Name,
+			"storage", storage.Name,
+			"host", storage.Viper.GetString("host"),
+			"bucket", storage.Viper.GetString("bucket"),
+			"destination", path.Join(storage.Viper.GetString("path"), fileKey),
+			"keep", storage.Viper.GetInt("keep"))
+		_, err = io.Copy(io.Discard, r)
+		return err
+	}
+
+	slog.Info("Storage operation details",
 		"component", "storage",
-		"type", model.StoreWith.Type,
+		"type", storage.Type,
 		"model", model.Name,
-		"fileKey", newFileKey)
-	err = ctx.open()
+		"storage", storage.Name,
+		"fileKey", fileKey)
+	runCtx, cancel := helper.WithTimeout(runCtx, "upload to "+storage.Name, storage.Timeout)
+	defer cancel()
+	closeStorage, err := openStorage(runCtx, ctx)
 	if err != nil {
 		return err
 	}
-	defer ctx.close()
+	defer closeStorage()
 
-	err = ctx.upload(newFileKey)
+	err = ctx.upload(runCtx, fileKey, helper.NewContextReader(runCtx, r))
 	if err != nil {
+		if ctxErr := helper.ContextErr(runCtx); ctxErr != nil {
+			return ctxErr
+		}
 		return err
 	}
 
-	slog.Info("Storage operation completed", 
+	return cycle(runCtx, ctx, model.Name, fileKey, storage.Viper.GetInt("keep"))
+}
+
+// openStorage opens ctx and closes it as soon as runCtx is done, so a
+// transfer hanging on the network fails. The returned func closes it
+// otherwise.
+func openStorage(runCtx context.Context, ctx Context) (closeStorage func(), err error) {
+	if err = ctx.open(); err != nil {
+		return nil, err
+	}
+	stop := context.AfterFunc(runCtx, ctx.close)
+	return func() {
+		if stop() {
+			ctx.close()
+		}
+	}, nil
+}
+
+// Download fetch a backup from the named storage of model into destDir,
+// the latest backup of the model is used when fileKey is empty. An empty storageName
+// picks the first storage of the model.
+func Download(runCtx context.Context, model config.ModelConfig, storageName, fileKey, destDir string) (filePath string, err error) {
+	storages := destinations(model)
+	if len(storages) == 0 {
+		return "", fmt.Errorf("model: %s has no storage config", model.Name)
+	}
+
+	var storage *config.SubConfig
+	for i := range storages {
+		if len(storageName) == 0 || storages[i].Name == storageName {
+			storage = &storages[i]
+			break
+		}
+	}
+	if storage == nil {
+		return "", fmt.Errorf("model: %s has no storage named %s", model.Name, storageName)
+	}
+
+	ctx, err := newContext(model, *storage)
+	if err != nil {
+		return "", err
+	}
+	closeStorage, err := openStorage(runCtx, ctx)
+	if err != nil {
+		return "", err
+	}
+	defer closeStorage()
+
+	if len(fileKey) == 0 {
+		if fileKey, err = latest(runCtx, ctx, model.Name); err != nil {
+			return "", err
+		}
+	}
+
+	filePath = filepath.Join(destDir, filepath.Base(fileKey))
+	slog.Info("Downloading backup",
 		"component", "storage",
-		"type", model.StoreWith.Type,
-		"model", model.Name)
-	return nil
+		"type", storage.Type,
+		"model", model.Name,
+		"storage", storage.Name,
+		"fileKey", fileKey,
+		"destination", filePath)
+
+	if err = ctx.download(runCtx, fileKey, filePath); err != nil {
+		return "", fmt.Errorf("download %s failed: %s", fileKey, err)
+	}
+	return filePath, nil
 }
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// backupTimeRegexp matches the time following the model name in a key
+	backupTimeRegexp = regexp.MustCompile(`^\d{4}\.\d{2}\.\d{2}\.\d{2}\.\d{2}\.\d{2}\.`)
+)
+
+// isModelBackupKey check whether the file key is a backup of the model,
+// example for model app: app.2006.01.02.15.04.05.tar.gz. The time must
+// follow the name, so model app doesn't match the backups of app.db.
+func isModelBackupKey(modelName, fileKey string) bool {
+	rest, ok := strings.CutPrefix(fileKey, modelName+".")
+	return ok && backupTimeRegexp.MatchString(rest)
+}
+
+// latest returns the file key of the newest backup of the model in storage
+func latest(runCtx context.Context, ctx Context, modelName string) (string, error) {
+	items, err := ctx.list(runCtx)
+	if err != nil {
+		return "", err
+	}
+
+	fileKey := ""
+	for _, item := range items {
+		if isModelBackupKey(modelName, item.Key) && item.Key > fileKey {
+			fileKey = item.Key
+		}
+	}
+	if len(fileKey) == 0 {
+		return "", fmt.Errorf("no backup of model %s found in storage", modelName)
+	}
+	return fileKey, nil
+}
+
+// cycle removes the oldest backups of the model so that only `keep` of them
+// are left, backups of other models sharing the storage are left alone. The
+// file just uploaded is never removed. keep <= 0 means keep everything.
+func cycle(runCtx context.Context, ctx Context, modelName, currentKey string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+
+	items, err := ctx.list(runCtx)
+	if err != nil {
+		return fmt.Errorf("list backups for retention failed: %s", err)
+	}
+
+	keys := []string{}
+	for _, item := range items {
+		if item.Key == currentKey || !isModelBackupKey(modelName, item.Key) {
+			continue
+		}
+		keys = append(keys, item.Key)
+	}
+
+	// file keys are timestamps, so newest first in reverse lexical order
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+
+	// the current backup takes one of the slots
+	if len(keys) < keep {
+		return nil
+	}
+	expired := keys[keep-1:]
+
+	slog.Info("Removing expired backups",
+		"component", "storage",
+		"model", modelName,
+		"keep", keep,
+		"count", len(expired))
+
+	for _, key := range expired {
+		if err := ctx.delete(runCtx, key); err != nil {
+			return fmt.Errorf("delete expired backup %s failed: %s", key, err)
+		}
+		slog.Debug("Expired backup removed",
+			"component", "storage",
+			"model", modelName,
+			"fileKey", key)
+	}
+
+	return nil
+}
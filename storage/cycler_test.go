@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// memStorage keeps file keys in memory
+type memStorage struct {
+	keys    map[string]bool
+	listErr error
+}
+
+func newMemStorage(keys ...string) *memStorage {
+	m := &memStorage{keys: map[string]bool{}}
+	for _, key := range keys {
+		m.keys[key] = true
+	}
+	return m
+}
+
+func (m *memStorage) open() error { return nil }
+func (m *memStorage) close()      {}
+
+func (m *memStorage) upload(runCtx context.Context, fileKey string, r io.Reader) error {
+	m.keys[fileKey] = true
+	return nil
+}
+
+func (m *memStorage) list(runCtx context.Context) (items []FileItem, err error) {
+	if m.listErr != nil {
+		return nil, m.listErr
+	}
+	for key := range m.keys {
+		items = append(items, FileItem{Key: key})
+	}
+	return
+}
+
+func (m *memStorage) delete(runCtx context.Context, fileKey string) error {
+	delete(m.keys, fileKey)
+	return nil
+}
+
+func (m *memStorage) download(runCtx context.Context, fileKey, destPath string) error {
+	return nil
+}
+
+func (m *memStorage) sorted() (keys []string) {
+	for key := range m.keys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return
+}
+
+func TestIsModelBackupKey(t *testing.T) {
+	cases := []struct {
+		model string
+		key   string
+		want  bool
+	}{
+		{model: "app", key: "app.2024.01.02.03.04.05.tar.gz", want: true},
+		{model: "app", key: "app.2024.01.02.03.04.05.tar.zst.aes", want: true},
+		{model: "app.db", key: "app.db.2024.01.02.03.04.05.tar.gz", want: true},
+		{model: "app", key: "app.db.2024.01.02.03.04.05.tar.gz", want: false},
+		{model: "app", key: "other.2024.01.02.03.04.05.tar.gz", want: false},
+		{model: "app", key: "2024.01.02.03.04.05.tar.gz", want: false},
+		{model: "app", key: "app.notes.txt", want: false},
+	}
+	for _, c := range cases {
+		if got := isModelBackupKey(c.model, c.key); got != c.want {
+			t.Errorf("isModelBackupKey(%q, %q) = %v, want %v", c.model, c.key, got, c.want)
+		}
+	}
+}
+
+func TestCycle(t *testing.T) {
+	existing := []string{
+		"test.2024.01.01.00.00.00.tar.gz",
+		"test.2024.01.02.00.00.00.tar.gz",
+		"test.2024.01.03.00.00.00.tar.gz",
+		// other models and backups made before keys had the model name
+		"other.2023.01.01.00.00.00.tar.gz",
+		"2023.01.01.00.00.00.tar.gz",
+		"notes.txt",
+	}
+	current := "test.2024.01.04.00.00.00.tar.gz"
+	untouched := existing[3:]
+
+	cases := []struct {
+		name string
+		keep int
+		want []string
+	}{
+		{
+			name: "keep everything",
+			keep: 0,
+			want: append(existing, current),
+		},
+		{
+			name: "keep only current",
+			keep: 1,
+			want: append([]string{current}, untouched...),
+		},
+		{
+			name: "keep two",
+			keep: 2,
+			want: append([]string{"test.2024.01.03.00.00.00.tar.gz", current}, untouched...),
+		},
+		{
+			name: "keep exactly all",
+			keep: 4,
+			want: append(existing, current),
+		},
+		{
+			name: "keep more than stored",
+			keep: 10,
+			want: append(existing, current),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := newMemStorage(append(existing, current)...)
+			if err := cycle(context.Background(), m, "test", current, c.keep); err != nil {
+				t.Fatalf("cycle() error = %v", err)
+			}
+			want := append([]string{}, c.want...)
+			sort.Strings(want)
+			if got := m.sorted(); !reflect.DeepEqual(got, want) {
+				t.Errorf("kept %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestCycleListError(t *testing.T) {
+	m := newMemStorage("test.2024.01.01.00.00.00.tar.gz")
+	m.listErr = errors.New("boom")
+	if err := cycle(context.Background(), m, "test", "test.2024.01.02.00.00.00.tar.gz", 1); err == nil {
+		t.Fatal("cycle() error = nil, want list error")
+	}
+	if len(m.keys) != 1 {
+		t.Errorf("backups deleted after a list error")
+	}
+}
+
+func TestLatest(t *testing.T) {
+	m := newMemStorage(
+		"app.2024.01.01.00.00.00.tar.gz",
+		"zzz.txt",
+		"app.2024.03.01.00.00.00.tar.gz",
+		"other.2024.04.01.00.00.00.tar.gz",
+		"2024.05.01.00.00.00.tar.gz",
+	)
+	got, err := latest(context.Background(), m, "app")
+	if err != nil || got != "app.2024.03.01.00.00.00.tar.gz" {
+		t.Errorf("latest() = %q, %v", got, err)
+	}
+
+	if _, err := latest(context.Background(), newMemStorage("notes.txt", "other.2024.04.01.00.00.00.tar.gz"), "app"); err == nil {
+		t.Errorf("latest() without backups error = nil")
+	}
+}
@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/holgerhuo/gobackup/config"
+)
+
+// Storage is a storage type added with Register, ctx is done when the run
+// is cancelled or the upload_timeout of the storage expired
+type Storage interface {
+	// Upload reads r until EOF into key, a partial upload should be
+	// removed when r fails
+	Upload(ctx context.Context, key string, r io.Reader) error
+	// Download writes the content of key into w
+	Download(ctx context.Context, key string, w io.Writer) error
+	// List every file, keep and restore pick the backups by name
+	List(ctx context.Context) ([]FileItem, error)
+	Delete(ctx context.Context, key string) error
+	// Close is called once the storage is not used anymore
+	Close() error
+}
+
+// Factory creates the Storage of store_with or an entry of `storages` in
+// model, settings are the keys of the entry like path or keep. It's called
+// right before the storage is used, connecting there is fine.
+type Factory func(model, name string, settings map[string]interface{}) (Storage, error)
+
+// newContextFunc creates the Context of a storage type
+type newContextFunc func(base Base) Context
+
+var (
+	registryMu sync.RWMutex
+	// registry of storage types, the built in types register from init
+	registry = map[string]newContextFunc{}
+)
+
+// register adds a storage type with its config schema
+func register(typ string, fn newContextFunc, schema config.Schema) {
+	config.RegisterSchema(config.SectionStorages, typ, schema)
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[typ] = fn
+}
+
+// Register adds a storage type, usable as `type: typ` in the config.
+// It panics when typ is taken, schema lists the keys the type accepts.
+func Register(typ string, factory Factory, schema config.Schema) {
+	if factory == nil {
+		panic("storage: Register factory is nil")
+	}
+	register(typ, func(base Base) Context {
+		return &external{Base: base, factory: factory}
+	}, schema)
+}
+
+// external runs a Storage added with Register or found as plugin
+type external struct {
+	Base
+	factory Factory
+	client  Storage
+}
+
+func (ctx *external) open() (err error) {
+	var settings map[string]interface{}
+	if ctx.viper != nil {
+		settings = ctx.viper.AllSettings()
+	}
+	ctx.client, err = ctx.factory(ctx.model.Name, ctx.storage.Name, settings)
+	return
+}
+
+func (ctx *external) close() {
+	if ctx.client != nil {
+		ctx.client.Close()
+	}
+}
+
+func (ctx *external) upload(runCtx context.Context, fileKey string, r io.Reader) error {
+	return ctx.client.Upload(runCtx, fileKey, r)
+}
+
+func (ctx *external) list(runCtx context.Context) ([]FileItem, error) {
+	return ctx.client.List(runCtx)
+}
+
+func (ctx *external) delete(runCtx context.Context, fileKey string) error {
+	return ctx.client.Delete(runCtx, fileKey)
+}
+
+func (ctx *external) download(runCtx context.Context, fileKey, destPath string) error {
+	file, err := os.OpenFile(destPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err = ctx.client.Download(runCtx, fileKey, file); err != nil {
+		os.Remove(destPath)
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"errors"
+	"io"
+)
+
+var (
+	errAllStoragesFailed = errors.New("all storages failed")
+)
+
+// fanoutWriter copies writes to every storage pipe, a pipe that fails is
+// dropped so one broken storage doesn't stop the others.
+type fanoutWriter struct {
+	writers []io.Writer
+}
+
+func newFanoutWriter(pipes []*io.PipeWriter) *fanoutWriter {
+	writers := make([]io.Writer, len(pipes))
+	for i, pw := range pipes {
+		writers[i] = pw
+	}
+	return &fanoutWriter{writers: writers}
+}
+
+func (f *fanoutWriter) Write(p []byte) (int, error) {
+	alive := f.writers[:0]
+	for _, w := range f.writers {
+		if _, err := w.Write(p); err == nil {
+			alive = append(alive, w)
+		}
+	}
+	f.writers = alive
+
+	if len(f.writers) == 0 {
+		return 0, errAllStoragesFailed
+	}
+	return len(p), nil
+}
@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/holgerhuo/gobackup/config"
+	"github.com/holgerhuo/gobackup/helper"
+	"github.com/jlaffaye/ftp"
+)
+
+// FTP storage, always uses passive mode
+//
+// type: ftp
+// host: your-host.com
+// port: 21
+// path: /backups
+// username: user1
+// password: pass1
+// tls: explicit # or implicit, empty for plain FTP
+// insecure_skip_verify: false
+// epsv: true # false to send PASV instead of EPSV
+// timeout: 30
+// keep: 15
+type FTP struct {
+	Base
+	destPath string
+	client   *ftp.ServerConn
+}
+
+func init() {
+	register("ftp", func(base Base) Context {
+		return &FTP{Base: base}
+	}, config.Schema{
+		Required: []string{"host"},
+		Optional: []string{"port", "path", "username", "password", "tls", "insecure_skip_verify", "epsv", "timeout"},
+	})
+}
+
+func (ctx *FTP) open() (err error) {
+	ctx.viper.SetDefault("timeout", 30)
+	ctx.viper.SetDefault("path", "/")
+	ctx.viper.SetDefault("epsv", true)
+	if ctx.viper.GetString("tls") == "implicit" {
+		ctx.viper.SetDefault("port", "990")
+	} else {
+		ctx.viper.SetDefault("port", "21")
+	}
+
+	host := helper.CleanHost(ctx.viper.GetString("host"))
+	if len(host) == 0 {
+		return fmt.Errorf("ftp host config is required")
+	}
+	ctx.destPath = ctx.viper.GetString("path")
+
+	opts := []ftp.DialOption{
+		ftp.DialWithTimeout(time.Duration(ctx.viper.GetInt("timeout")) * time.Second),
+		ftp.DialWithDisabledEPSV(!ctx.viper.GetBool("epsv")),
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: ctx.viper.GetBool("insecure_skip_verify"),
+	}
+	switch ctx.viper.GetString("tls") {
+	case "":
+	case "explicit":
+		opts = append(opts, ftp.DialWithExplicitTLS(tlsConfig))
+	case "implicit":
+		opts = append(opts, ftp.DialWithTLS(tlsConfig))
+	default:
+		return fmt.Errorf("ftp tls config must be explicit or implicit, got %s", ctx.viper.GetString("tls"))
+	}
+
+	addr := net.JoinHostPort(host, ctx.viper.GetString("port"))
+	ctx.client, err = ftp.Dial(addr, opts...)
+	if err != nil {
+		return fmt.Errorf("ftp connect to %s failed: %s", addr, err)
+	}
+
+	if err = ctx.client.Login(ctx.viper.GetString("username"), ctx.viper.GetString("password")); err != nil {
+		ctx.close()
+		return fmt.Errorf("ftp login failed: %s", err)
+	}
+
+	// relative paths start from the login directory
+	if !path.IsAbs(ctx.destPath) {
+		cwd, err := ctx.client.CurrentDir()
+		if err != nil {
+			ctx.close()
+			return fmt.Errorf("ftp get current dir failed: %s", err)
+		}
+		ctx.destPath = path.Join(cwd, ctx.destPath)
+	}
+
+	if err = ctx.mkdirP(ctx.destPath); err != nil {
+		ctx.close()
+		return fmt.Errorf("create remote path %s failed: %s", ctx.destPath, err)
+	}
+
+	return nil
+}
+
+// mkdirP creates every missing directory of the absolute dirPath, like mkdir -p
+func (ctx *FTP) mkdirP(dirPath string) error {
+	current := "/"
+	for _, part := range strings.Split(dirPath, "/") {
+		if len(part) == 0 {
+			continue
+		}
+		current = path.Join(current, part)
+
+		if err := ctx.client.ChangeDir(current); err == nil {
+			continue
+		}
+		if err := ctx.client.MakeDir(current); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ctx *FTP) close() {
+	if ctx.client != nil {
+		ctx.client.Quit()
+	}
+}
+
+func (ctx *FTP) upload(runCtx context.Context, fileKey string, r io.Reader) (err error) {
+	remotePath := path.Join(ctx.destPath, fileKey)
+
+	slog.Info("Uploading to FTP",
+		"component", "storage",
+		"type", "ftp",
+		"model", ctx.model.Name,
+		"path", remotePath)
+
+	if err = ctx.client.Stor(remotePath, r); err != nil {
+		ctx.client.Delete(remotePath)
+		return fmt.Errorf("failed to upload file, %v", err)
+	}
+
+	slog.Info("FTP upload successful",
+		"component", "storage",
+		"type", "ftp",
+		"model", ctx.model.Name,
+		"path", remotePath)
+	return nil
+}
+
+func (ctx *FTP) list(runCtx context.Context) (items []FileItem, err error) {
+	entries, err := ctx.client.List(ctx.destPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.Type != ftp.EntryTypeFile {
+			continue
+		}
+		items = append(items, FileItem{
+			Key:          path.Base(entry.Name),
+			Size:         int64(entry.Size),
+			LastModified: entry.Time,
+		})
+	}
+	return
+}
+
+func (ctx *FTP) delete(runCtx context.Context, fileKey string) error {
+	return ctx.client.Delete(path.Join(ctx.destPath, fileKey))
+}
+
+func (ctx *FTP) download(runCtx context.Context, fileKey, destPath string) (err error) {
+	resp, err := ctx.client.Retr(path.Join(ctx.destPath, fileKey))
+	if err != nil {
+		return err
+	}
+	defer resp.Close()
+
+	file, err := os.Create(destPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(file, helper.NewContextReader(runCtx, resp))
+	return err
+}
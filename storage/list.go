@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/holgerhuo/gobackup/config"
+)
+
+// Listing backups kept in one storage of a model
+type Listing struct {
+	Model   string
+	Storage string
+	Type    string
+	Items   []FileItem
+	Err     error
+}
+
+// List queries every storage of model for its backups, newest first.
+// Backups of other models sharing a storage are left out.
+// A failing storage is reported in Listing.Err and does not stop the others.
+func List(runCtx context.Context, model config.ModelConfig) (listings []Listing) {
+	for _, storage := range destinations(model) {
+		listing := Listing{
+			Model:   model.Name,
+			Storage: storage.Name,
+			Type:    storage.Type,
+		}
+		listing.Items, listing.Err = listStorage(runCtx, model, storage)
+		listings = append(listings, listing)
+	}
+	return
+}
+
+func listStorage(runCtx context.Context, model config.ModelConfig, storage config.SubConfig) (items []FileItem, err error) {
+	ctx, err := newContext(model, storage)
+	if err != nil {
+		return nil, err
+	}
+	closeStorage, err := openStorage(runCtx, ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer closeStorage()
+
+	all, err := ctx.list(runCtx)
+	if err != nil {
+		return nil, fmt.Errorf("list backups failed: %s", err)
+	}
+	for _, item := range all {
+		if isModelBackupKey(model.Name, item.Key) {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Key > items[j].Key
+	})
+	return items, nil
+}
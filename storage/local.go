@@ -1,8 +1,13 @@
 package storage
 
 import (
+	"context"
+	"io"
 	"log/slog"
+	"os"
+	"path/filepath"
 
+	"github.com/holgerhuo/gobackup/config"
 	"github.com/holgerhuo/gobackup/helper"
 )
 
@@ -10,36 +15,96 @@ import (
 //
 // type: local
 // path: /data/backups
+// keep: 10
 type Local struct {
 	Base
 	destPath string
 }
 
+func init() {
+	register("local", func(base Base) Context {
+		return &Local{Base: base}
+	}, config.Schema{
+		Required: []string{"path"},
+	})
+}
+
 func (ctx *Local) open() (err error) {
-	ctx.destPath = ctx.model.StoreWith.Viper.GetString("path")
+	ctx.destPath = ctx.viper.GetString("path")
 	helper.MkdirP(ctx.destPath)
 	return
 }
 
 func (ctx *Local) close() {}
 
-func (ctx *Local) upload(fileKey string) (err error) {
-	_, err = helper.Exec("cp", ctx.archivePath, ctx.destPath)
+func (ctx *Local) upload(runCtx context.Context, fileKey string, r io.Reader) (err error) {
+	destPath := filepath.Join(ctx.destPath, fileKey)
+	err = writeFile(destPath, r)
 	if err != nil {
 		slog.Error("Local storage upload failed",
 			"component", "storage",
 			"type", "local",
 			"model", ctx.model.Name,
-			"source", ctx.archivePath,
-			"destination", ctx.destPath,
+			"destination", destPath,
 			"error", err)
 		return err
 	}
-	
+
 	slog.Info("Local storage upload successful",
 		"component", "storage",
 		"type", "local",
 		"model", ctx.model.Name,
-		"destination", ctx.destPath)
+		"destination", destPath)
 	return nil
 }
+
+// writeFile copies r into filePath, removing the file when copy fails
+func writeFile(filePath string, r io.Reader) error {
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(file, r); err != nil {
+		file.Close()
+		os.Remove(filePath)
+		return err
+	}
+	return file.Close()
+}
+
+func (ctx *Local) list(runCtx context.Context) (items []FileItem, err error) {
+	entries, err := os.ReadDir(ctx.destPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, FileItem{
+			Key:          entry.Name(),
+			Size:         info.Size(),
+			LastModified: info.ModTime(),
+		})
+	}
+	return
+}
+
+func (ctx *Local) delete(runCtx context.Context, fileKey string) error {
+	return os.Remove(filepath.Join(ctx.destPath, fileKey))
+}
+
+func (ctx *Local) download(runCtx context.Context, fileKey, destPath string) error {
+	file, err := os.Open(filepath.Join(ctx.destPath, fileKey))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return writeFile(destPath, helper.NewContextReader(runCtx, file))
+}
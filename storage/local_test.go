@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/holgerhuo/gobackup/config"
+)
+
+func TestLocalDownload(t *testing.T) {
+	storeDir := t.TempDir()
+	model, err := config.NewModel("app", map[string]interface{}{
+		"store_with": map[string]interface{}{"type": "local", "path": storeDir},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(storeDir, "app.2024.01.01.00.00.00.tar"), []byte("backup"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	filePath, err := Download(context.Background(), model, "", "", t.TempDir())
+	if err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	if data, err := os.ReadFile(filePath); err != nil || string(data) != "backup" {
+		t.Errorf("downloaded %q, %v", data, err)
+	}
+
+	// a cancelled download leaves no partial file behind
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	destDir := t.TempDir()
+	if _, err = Download(ctx, model, "", "app.2024.01.01.00.00.00.tar", destDir); err == nil {
+		t.Error("Download() of a cancelled run error = nil")
+	}
+	if entries, _ := os.ReadDir(destDir); len(entries) > 0 {
+		t.Errorf("cancelled download left %d file(s)", len(entries))
+	}
+}
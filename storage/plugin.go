@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"io"
+
+	"github.com/holgerhuo/gobackup/plugin"
+)
+
+// pluginStorage runs a storage type of an exec plugin
+type pluginStorage struct {
+	plugin   *plugin.Plugin
+	model    string
+	name     string
+	settings map[string]interface{}
+}
+
+func pluginFactory(p *plugin.Plugin) Factory {
+	return func(model, name string, settings map[string]interface{}) (Storage, error) {
+		return &pluginStorage{plugin: p, model: model, name: name, settings: settings}, nil
+	}
+}
+
+func (s *pluginStorage) request(method, key string) plugin.Request {
+	req := s.plugin.NewRequest(method)
+	req.Model = s.model
+	req.Name = s.name
+	req.Key = key
+	req.Settings = s.settings
+	return req
+}
+
+func (s *pluginStorage) Upload(ctx context.Context, key string, r io.Reader) error {
+	return s.plugin.Call(ctx, s.request("upload", key), r, nil)
+}
+
+func (s *pluginStorage) Download(ctx context.Context, key string, w io.Writer) error {
+	return s.plugin.Call(ctx, s.request("download", key), nil, w)
+}
+
+func (s *pluginStorage) List(ctx context.Context) ([]FileItem, error) {
+	var items []plugin.Item
+	if err := s.plugin.CallJSON(ctx, s.request("list", ""), &items); err != nil {
+		return nil, err
+	}
+	files := make([]FileItem, 0, len(items))
+	for _, item := range items {
+		files = append(files, FileItem{Key: item.Key, Size: item.Size, LastModified: item.LastModified})
+	}
+	return files, nil
+}
+
+func (s *pluginStorage) Delete(ctx context.Context, key string) error {
+	return s.plugin.Call(ctx, s.request("delete", key), nil, nil)
+}
+
+func (s *pluginStorage) Close() error {
+	return nil
+}
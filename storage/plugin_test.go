@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/holgerhuo/gobackup/config"
+	"github.com/holgerhuo/gobackup/plugin"
+)
+
+// stubStoragePlugin puts a gobackup-storage-stub plugin keeping the backups
+// in a dir into plugin.Dirs, it returns the dir and the log of its requests
+func stubStoragePlugin(t *testing.T) (storeDir, logPath string) {
+	binDir := t.TempDir()
+	storeDir = t.TempDir()
+	logPath = filepath.Join(t.TempDir(), "requests.log")
+	script := `#!/bin/sh
+read -r req
+echo "$req" >> ` + logPath + `
+method=$(echo "$req" | sed -n 's/.*"method":"\([a-z]*\)".*/\1/p')
+key=$(echo "$req" | sed -n 's/.*"key":"\([^"]*\)".*/\1/p')
+case "$method" in
+upload) cat > "` + storeDir + `/$key" ;;
+download) cat "` + storeDir + `/$key" ;;
+delete) rm "` + storeDir + `/$key" ;;
+list)
+	sep=
+	printf '['
+	for f in "` + storeDir + `"/*; do
+		[ -e "$f" ] || continue
+		printf '%s{"key":"%s","size":%d}' "$sep" "$(basename "$f")" "$(wc -c < "$f")"
+		sep=,
+	done
+	printf ']'
+	;;
+*) echo "unknown method $method" >&2; exit 1 ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(binDir, "gobackup-storage-stub"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	dirs := plugin.Dirs
+	plugin.Dirs = []string{binDir}
+	t.Cleanup(func() { plugin.Dirs = dirs })
+	return
+}
+
+func TestRunPlugin(t *testing.T) {
+	storeDir, logPath := stubStoragePlugin(t)
+
+	model, err := config.NewModel("app", map[string]interface{}{
+		"store_with": map[string]interface{}{"type": "stub", "keep": 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archiveDir := t.TempDir()
+	keys := []string{"app.2024.01.01.00.00.00.tar", "app.2024.01.02.00.00.00.tar"}
+	// a backup of another model in the same storage
+	if err = os.WriteFile(filepath.Join(storeDir, "other.2023.01.01.00.00.00.tar"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		archivePath := filepath.Join(archiveDir, key)
+		if err = os.WriteFile(archivePath, []byte("backup "+key), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err = Run(context.Background(), model, archivePath); err != nil {
+			t.Fatalf("Run(%s) error = %v", key, err)
+		}
+	}
+
+	entries, err := os.ReadDir(storeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored []string
+	for _, entry := range entries {
+		stored = append(stored, entry.Name())
+	}
+	sort.Strings(stored)
+	if want := []string{keys[1], "other.2023.01.01.00.00.00.tar"}; !reflect.DeepEqual(stored, want) {
+		t.Errorf("stored %v, want %v", stored, want)
+	}
+	data, err := os.ReadFile(filepath.Join(storeDir, keys[1]))
+	if err != nil || string(data) != "backup "+keys[1] {
+		t.Errorf("uploaded %q, %v", data, err)
+	}
+
+	data, err = os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var methods []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		for _, method := range []string{"upload", "list", "delete"} {
+			if strings.Contains(line, `"method":"`+method+`"`) {
+				methods = append(methods, method)
+			}
+		}
+		if !strings.Contains(line, `"model":"app"`) || !strings.Contains(line, `"kind":"storage"`) {
+			t.Errorf("request without model or kind: %s", line)
+		}
+	}
+	if want := []string{"upload", "list", "upload", "list", "delete"}; !reflect.DeepEqual(methods, want) {
+		t.Errorf("methods %v, want %v", methods, want)
+	}
+
+	filePath, err := Download(context.Background(), model, "", "", t.TempDir())
+	if err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	if data, err = os.ReadFile(filePath); err != nil || string(data) != "backup "+keys[1] {
+		t.Errorf("downloaded %q, %v", data, err)
+	}
+}
+
+func TestRunPluginError(t *testing.T) {
+	storeDir, _ := stubStoragePlugin(t)
+	// the plugin fails to write the upload
+	if err := os.Remove(storeDir); err != nil {
+		t.Fatal(err)
+	}
+
+	model, err := config.NewModel("app", map[string]interface{}{
+		"store_with": map[string]interface{}{"type": "stub"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archivePath := filepath.Join(t.TempDir(), "app.2024.01.01.00.00.00.tar")
+	if err = os.WriteFile(archivePath, []byte("backup"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	err = Run(context.Background(), model, archivePath)
+	if err == nil || !strings.Contains(err.Error(), "gobackup-storage-stub upload") {
+		t.Errorf("Run() error = %v, want the upload error of the plugin", err)
+	}
+}
@@ -1,15 +1,20 @@
 package storage
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/holgerhuo/gobackup/config"
 )
 
 // S3 - Amazon S3 storage
@@ -22,11 +27,26 @@ import (
 // secret_access_key: your-secret-access-key
 // max_retries: 5
 // timeout: 300
+// part_size: 64MB # streamed uploads can't exceed 10000 parts
+// keep: 20
 type S3 struct {
 	Base
-	bucket string
-	path   string
-	client *s3manager.Uploader
+	bucket   string
+	path     string
+	client   *s3manager.Uploader
+	s3Client *s3.S3
+}
+
+func init() {
+	register("s3", func(base Base) Context {
+		return &S3{Base: base}
+	}, config.Schema{
+		Required: []string{"bucket"},
+		Optional: []string{
+			"region", "path", "endpoint", "access_key_id", "secret_access_key", "token",
+			"max_retries", "timeout", "force_path_style", "part_size",
+		},
+	})
 }
 
 func (ctx *S3) open() (err error) {
@@ -50,35 +70,38 @@ func (ctx *S3) open() (err error) {
 	cfg.S3ForcePathStyle = aws.Bool(ctx.viper.GetBool("force_path_style"))
 
 	sess := session.Must(session.NewSession(cfg))
-	ctx.client = s3manager.NewUploader(sess)
+	ctx.viper.SetDefault("part_size", "64MB")
+	partSize := int64(ctx.viper.GetSizeInBytes("part_size"))
+	ctx.client = s3manager.NewUploader(sess, func(u *s3manager.Uploader) {
+		if partSize >= s3manager.MinUploadPartSize {
+			u.PartSize = partSize
+		}
+	})
+	ctx.s3Client = s3.New(sess)
 
 	return
 }
 
 func (ctx *S3) close() {}
 
-func (ctx *S3) upload(fileKey string) (err error) {
-	f, err := os.Open(ctx.archivePath)
-	if err != nil {
-		return fmt.Errorf("failed to open file %q, %v", ctx.archivePath, err)
-	}
-
+// upload with multipart, a failed upload is aborted by s3manager
+func (ctx *S3) upload(runCtx context.Context, fileKey string, r io.Reader) (err error) {
 	remotePath := filepath.Join(ctx.path, fileKey)
 
 	input := &s3manager.UploadInput{
 		Bucket: aws.String(ctx.bucket),
 		Key:    aws.String(remotePath),
-		Body:   f,
+		Body:   r,
 	}
 
-	slog.Info("Uploading to S3", 
+	slog.Info("Uploading to S3",
 		"component", "storage",
 		"type", "s3",
 		"model", ctx.model.Name,
 		"bucket", ctx.bucket,
 		"path", remotePath)
-	
-	result, err := ctx.client.Upload(input)
+
+	result, err := ctx.client.UploadWithContext(runCtx, input)
 	if err != nil {
 		slog.Error("S3 upload failed",
 			"component", "storage",
@@ -90,10 +113,73 @@ func (ctx *S3) upload(fileKey string) (err error) {
 		return fmt.Errorf("failed to upload file, %v", err)
 	}
 
-	slog.Info("S3 upload successful", 
+	slog.Info("S3 upload successful",
 		"component", "storage",
 		"type", "s3",
 		"model", ctx.model.Name,
 		"location", result.Location)
 	return nil
 }
+
+func (ctx *S3) list(runCtx context.Context) (items []FileItem, err error) {
+	prefix := ""
+	if len(ctx.path) > 0 {
+		prefix = strings.TrimSuffix(ctx.path, "/") + "/"
+	}
+
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(ctx.bucket),
+		Prefix: aws.String(prefix),
+	}
+	err = ctx.s3Client.ListObjectsV2PagesWithContext(runCtx, input, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			key := strings.TrimPrefix(aws.StringValue(object.Key), prefix)
+			// skip objects in sub directories
+			if len(key) == 0 || strings.Contains(key, "/") {
+				continue
+			}
+			items = append(items, FileItem{
+				Key:          key,
+				Size:         aws.Int64Value(object.Size),
+				LastModified: aws.TimeValue(object.LastModified),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects, %v", err)
+	}
+	return
+}
+
+func (ctx *S3) delete(runCtx context.Context, fileKey string) (err error) {
+	remotePath := filepath.Join(ctx.path, fileKey)
+
+	_, err = ctx.s3Client.DeleteObjectWithContext(runCtx, &s3.DeleteObjectInput{
+		Bucket: aws.String(ctx.bucket),
+		Key:    aws.String(remotePath),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete object %q, %v", remotePath, err)
+	}
+	return
+}
+
+func (ctx *S3) download(runCtx context.Context, fileKey, destPath string) (err error) {
+	f, err := os.Create(destPath)
+	if err != nil {
+		return fmt.Errorf("failed to create file %q, %v", destPath, err)
+	}
+	defer f.Close()
+
+	remotePath := filepath.Join(ctx.path, fileKey)
+	downloader := s3manager.NewDownloaderWithClient(ctx.s3Client)
+	_, err = downloader.DownloadWithContext(runCtx, f, &s3.GetObjectInput{
+		Bucket: aws.String(ctx.bucket),
+		Key:    aws.String(remotePath),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to download object %q, %v", remotePath, err)
+	}
+	return
+}
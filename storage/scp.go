@@ -0,0 +1,221 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/holgerhuo/gobackup/config"
+	"github.com/holgerhuo/gobackup/helper"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SCP storage, upload over SFTP with a pure Go SSH client
+//
+// type: scp # or sftp
+// host: your-host.com
+// port: 22
+// path: ~/backup
+// username: ubuntu
+// password: password
+// private_key: ~/.ssh/id_rsa
+// passphrase:
+// known_hosts: ~/.ssh/known_hosts
+// timeout: 300
+// keep: 10
+type SCP struct {
+	Base
+	destPath  string
+	sshClient *ssh.Client
+	client    *sftp.Client
+}
+
+func init() {
+	newSCP := func(base Base) Context {
+		return &SCP{Base: base}
+	}
+	schema := config.Schema{
+		Required: []string{"host", "username"},
+		Optional: []string{"port", "path", "password", "private_key", "passphrase", "known_hosts", "timeout"},
+	}
+	register("scp", newSCP, schema)
+	register("sftp", newSCP, schema)
+}
+
+func (ctx *SCP) open() (err error) {
+	ctx.viper.SetDefault("port", "22")
+	ctx.viper.SetDefault("timeout", 300)
+	ctx.viper.SetDefault("known_hosts", "~/.ssh/known_hosts")
+
+	host := helper.CleanHost(ctx.viper.GetString("host"))
+	if len(host) == 0 {
+		return fmt.Errorf("scp host config is required")
+	}
+	username := ctx.viper.GetString("username")
+	if len(username) == 0 {
+		return fmt.Errorf("scp username config is required")
+	}
+
+	// SFTP resolves relative paths from the user's home directory
+	ctx.destPath = ctx.viper.GetString("path")
+	ctx.destPath = strings.TrimPrefix(ctx.destPath, "~/")
+	if ctx.destPath == "~" || len(ctx.destPath) == 0 {
+		ctx.destPath = "."
+	}
+
+	auths, err := ctx.authMethods()
+	if err != nil {
+		return err
+	}
+
+	hostKeyCallback, err := knownhosts.New(helper.ExplandHome(ctx.viper.GetString("known_hosts")))
+	if err != nil {
+		return fmt.Errorf("load known_hosts failed: %s", err)
+	}
+
+	clientConfig := &ssh.ClientConfig{
+		User:            username,
+		Auth:            auths,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         time.Duration(ctx.viper.GetInt("timeout")) * time.Second,
+	}
+
+	addr := net.JoinHostPort(host, ctx.viper.GetString("port"))
+	ctx.sshClient, err = ssh.Dial("tcp", addr, clientConfig)
+	if err != nil {
+		return fmt.Errorf("ssh connect to %s failed: %s", addr, err)
+	}
+
+	ctx.client, err = sftp.NewClient(ctx.sshClient)
+	if err != nil {
+		ctx.sshClient.Close()
+		return fmt.Errorf("sftp session failed: %s", err)
+	}
+
+	if err = ctx.client.MkdirAll(ctx.destPath); err != nil {
+		ctx.close()
+		return fmt.Errorf("create remote path %s failed: %s", ctx.destPath, err)
+	}
+
+	return nil
+}
+
+func (ctx *SCP) authMethods() (auths []ssh.AuthMethod, err error) {
+	privateKey := ctx.viper.GetString("private_key")
+	if len(privateKey) > 0 {
+		keyData, err := os.ReadFile(helper.ExplandHome(privateKey))
+		if err != nil {
+			return nil, fmt.Errorf("read private_key failed: %s", err)
+		}
+
+		var signer ssh.Signer
+		passphrase := ctx.viper.GetString("passphrase")
+		if len(passphrase) > 0 {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(keyData, []byte(passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(keyData)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse private_key failed: %s", err)
+		}
+		auths = append(auths, ssh.PublicKeys(signer))
+	}
+
+	password := ctx.viper.GetString("password")
+	if len(password) > 0 {
+		auths = append(auths, ssh.Password(password))
+	}
+
+	if len(auths) == 0 {
+		return nil, fmt.Errorf("scp private_key or password config is required")
+	}
+	return auths, nil
+}
+
+func (ctx *SCP) close() {
+	if ctx.client != nil {
+		ctx.client.Close()
+	}
+	if ctx.sshClient != nil {
+		ctx.sshClient.Close()
+	}
+}
+
+func (ctx *SCP) upload(runCtx context.Context, fileKey string, r io.Reader) (err error) {
+	remotePath := path.Join(ctx.destPath, fileKey)
+
+	slog.Info("Uploading over SFTP",
+		"component", "storage",
+		"type", "scp",
+		"model", ctx.model.Name,
+		"path", remotePath)
+
+	remoteFile, err := ctx.client.Create(remotePath)
+	if err != nil {
+		return fmt.Errorf("failed to create remote file %q, %v", remotePath, err)
+	}
+
+	if _, err = io.Copy(remoteFile, r); err != nil {
+		remoteFile.Close()
+		ctx.client.Remove(remotePath)
+		return fmt.Errorf("failed to upload file, %v", err)
+	}
+	if err = remoteFile.Close(); err != nil {
+		return fmt.Errorf("failed to upload file, %v", err)
+	}
+
+	slog.Info("SFTP upload successful",
+		"component", "storage",
+		"type", "scp",
+		"model", ctx.model.Name,
+		"path", remotePath)
+	return nil
+}
+
+func (ctx *SCP) list(runCtx context.Context) (items []FileItem, err error) {
+	infos, err := ctx.client.ReadDir(ctx.destPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, info := range infos {
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		items = append(items, FileItem{
+			Key:          info.Name(),
+			Size:         info.Size(),
+			LastModified: info.ModTime(),
+		})
+	}
+	return
+}
+
+func (ctx *SCP) delete(runCtx context.Context, fileKey string) error {
+	return ctx.client.Remove(path.Join(ctx.destPath, fileKey))
+}
+
+func (ctx *SCP) download(runCtx context.Context, fileKey, destPath string) (err error) {
+	remoteFile, err := ctx.client.Open(path.Join(ctx.destPath, fileKey))
+	if err != nil {
+		return err
+	}
+	defer remoteFile.Close()
+
+	file, err := os.Create(destPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(file, helper.NewContextReader(runCtx, remoteFile))
+	return err
+}